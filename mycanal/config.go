@@ -1,11 +1,18 @@
 package mycanal
 
 import (
+	"crypto/tls"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
 )
 
 // Config is used for fulldump and incrdump.
@@ -22,27 +29,187 @@ type Config struct {
 	// Password for connection.
 	Password string `json:"password"`
 
+	// PasswordProvider, if not nil, is consulted for password (instead of Password) on every new connection
+	// of Client and every (re)connection of incrdump. ToDriverCfg/ToBinlogSyncerCfg still use Password.
+	//
+	// When it's used, incrdump reconnects the binlog stream by itself on connection errors (mysql.ErrBadConn,
+	// including read timeouts, see ReadTimeout). Errors returned by the server (e.g. the dump thread being
+	// killed) are not retried. All errors of reconnection (e.g. authentication failure before the password
+	// is rotated) are retried, up to MaxReconnectAttempts.
+	PasswordProvider PasswordProvider `json:"-"`
+
 	// Charset for connecting.
 	Charset string `json:"charset"`
 
-	// ServerId is used by incrdump only (as a replication node).
+	// ServerId is used by incrdump only (as a replication node). See AllocServerId to allocate one automatically.
 	ServerId uint32 `json:"serverId"`
+
+	// Socket is the unix socket path. If set, Host and Port are ignored.
+	Socket string `json:"socket"`
+
+	// Params are extra go-sql-driver/mysql DSN params (driver options or session system variables),
+	// e.g. {"maxAllowedPacket": "0", "sql_mode": "'TRADITIONAL'"}. Not used by the binlog stream.
+	Params map[string]string `json:"params"`
+
+	// Timeout is the dial timeout of connections.
+	//
+	// Use DefaultTimeout if not set.
+	Timeout time.Duration `json:"timeout"`
+
+	// ReadTimeout is the I/O read timeout of connections, 0 for no timeout.
+	// It's also the read timeout of the binlog stream, see HeartbeatPeriod.
+	ReadTimeout time.Duration `json:"readTimeout"`
+
+	// WriteTimeout is the I/O write timeout of connections (not including the binlog stream), 0 for no timeout.
+	WriteTimeout time.Duration `json:"writeTimeout"`
+
+	// HeartbeatPeriod is the heartbeat period of the binlog stream so that an idle stream does not reach ReadTimeout.
+	//
+	// Use half of ReadTimeout if not set and ReadTimeout is set.
+	HeartbeatPeriod time.Duration `json:"heartbeatPeriod"`
+
+	// MaxReconnectAttempts is the max reconnect attempts of the binlog stream, 0 for no limit.
+	MaxReconnectAttempts int `json:"maxReconnectAttempts"`
+
+	// MaxOpenConns, MaxIdleConns, ConnMaxLifetime and ConnMaxIdleTime are applied to the *sql.DB
+	// returned by Client, 0 for database/sql's defaults.
+	MaxOpenConns    int           `json:"maxOpenConns"`
+	MaxIdleConns    int           `json:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `json:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `json:"connMaxIdleTime"`
+
+	// TLS is the TLS mode, same as the 'tls' param of go-sql-driver/mysql:
+	//   - "" or "false": no TLS
+	//   - "true": TLS with server certificate verification
+	//   - "skip-verify": TLS without server certificate verification
+	//   - "preferred": TLS without verification if the server supports it, otherwise no TLS
+	//   - other: name of a tls.Config registered by RegisterTLSConfig
+	TLS string `json:"tls"`
+
+	// UTCSession sets session time_zone to '+00:00' for connections of Client, so that TIMESTAMP values
+	// returned by fulldump (read as UTC) are the same instants as the ones returned by incrdump. Otherwise
+	// TIMESTAMP values are in the server's time zone but read as UTC, which is only correct if the
+	// server's time zone is UTC. It can't be used with the time_zone param.
+	UTCSession bool `json:"utcSession"`
 }
 
-// ToDriverCfg converts cfg to mysql driver config.
+var (
+	// DefaultTimeout is the default value of Config.Timeout.
+	DefaultTimeout = 10 * time.Second
+)
+
+var (
+	tlsConfigsMu sync.RWMutex
+	tlsConfigs   = map[string]*tls.Config{}
+)
+
+// RegisterTLSConfig registers a custom tls.Config to be used in Config.TLS, for both
+// fulldump (go-sql-driver/mysql) and incrdump (go-mysql).
+func RegisterTLSConfig(name string, config *tls.Config) error {
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return err
+	}
+	tlsConfigsMu.Lock()
+	tlsConfigs[name] = config
+	tlsConfigsMu.Unlock()
+	return nil
+}
+
+// ToDriverCfg converts cfg to mysql driver config. It panics if Params are invalid, use Client to get an error instead.
 func (cfg *Config) ToDriverCfg() *mysql.Config {
+	ret, err := cfg.driverCfg()
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+func (cfg *Config) driverCfg() (*mysql.Config, error) {
 	ret := mysql.NewConfig()
-	ret.Net = "tcp"
-	ret.Addr = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	if cfg.Socket != "" {
+		ret.Net = "unix"
+		ret.Addr = cfg.Socket
+	} else {
+		ret.Net = "tcp"
+		ret.Addr = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	}
 	ret.User = cfg.User
 	ret.Passwd = cfg.Password
 	ret.ParseTime = true
 	ret.InterpolateParams = true
+	ret.Timeout = cfg.getTimeout()
+	ret.ReadTimeout = cfg.ReadTimeout
+	ret.WriteTimeout = cfg.WriteTimeout
+	if cfg.TLS != "" {
+		ret.TLSConfig = cfg.TLS
+	}
+
+	// Extra params are parsed by the driver.
+	if len(cfg.Params) != 0 {
+		values := url.Values{}
+		for name, value := range cfg.Params {
+			values.Set(name, value)
+		}
+		extra, err := mysql.ParseDSN("/?" + values.Encode())
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid params")
+		}
+		if err := mergeDriverParams(ret, extra, cfg.Params); err != nil {
+			return nil, err
+		}
+	}
+
 	if ret.Params == nil {
 		ret.Params = map[string]string{}
 	}
 	ret.Params["charset"] = cfg.getCharset()
-	return ret
+	if cfg.UTCSession {
+		if _, ok := ret.Params["time_zone"]; ok {
+			return nil, errors.New("param \"time_zone\" can't be used with UTCSession")
+		}
+		ret.Params["time_zone"] = "'+00:00'"
+	}
+	return ret, nil
+}
+
+// mergeDriverParams copies driver options and system variables parsed from params to dst.
+func mergeDriverParams(dst, src *mysql.Config, params map[string]string) error {
+	for name := range params {
+		switch name {
+		case "allowAllFiles":
+			dst.AllowAllFiles = src.AllowAllFiles
+		case "allowCleartextPasswords":
+			dst.AllowCleartextPasswords = src.AllowCleartextPasswords
+		case "allowNativePasswords":
+			dst.AllowNativePasswords = src.AllowNativePasswords
+		case "allowOldPasswords":
+			dst.AllowOldPasswords = src.AllowOldPasswords
+		case "checkConnLiveness":
+			dst.CheckConnLiveness = src.CheckConnLiveness
+		case "clientFoundRows":
+			dst.ClientFoundRows = src.ClientFoundRows
+		case "collation":
+			dst.Collation = src.Collation
+		case "columnsWithAlias":
+			dst.ColumnsWithAlias = src.ColumnsWithAlias
+		case "maxAllowedPacket":
+			dst.MaxAllowedPacket = src.MaxAllowedPacket
+		case "multiStatements":
+			dst.MultiStatements = src.MultiStatements
+		case "rejectReadOnly":
+			dst.RejectReadOnly = src.RejectReadOnly
+		case "serverPubKey":
+			dst.ServerPubKey = src.ServerPubKey
+		case "charset", "parseTime", "loc", "interpolateParams", "timeout", "readTimeout", "writeTimeout", "tls":
+			return errors.Errorf("param %q can't be overridden", name)
+		default:
+			if dst.Params == nil {
+				dst.Params = map[string]string{}
+			}
+			dst.Params[name] = src.Params[name]
+		}
+	}
+	return nil
 }
 
 // ToBinlogSyncerCfg converts cfg to binlog syncer config. Needs ServerId.
@@ -50,21 +217,60 @@ func (cfg *Config) ToBinlogSyncerCfg() replication.BinlogSyncerConfig {
 	if cfg.ServerId == 0 {
 		panic(fmt.Errorf("ToBinlogSyncerCfg: no ServerId"))
 	}
-	return replication.BinlogSyncerConfig{
-		ServerID:   cfg.ServerId,
-		Host:       cfg.Host,
-		Port:       cfg.Port,
-		User:       cfg.User,
-		Password:   cfg.Password,
-		Charset:    cfg.getCharset(),
-		ParseTime:  true,
-		UseDecimal: true,
+	ret := replication.BinlogSyncerConfig{
+		ServerID:             cfg.ServerId,
+		Host:                 cfg.Host,
+		Port:                 cfg.Port,
+		User:                 cfg.User,
+		Password:             cfg.Password,
+		Charset:              cfg.getCharset(),
+		TLSConfig:            cfg.getTLSConfig(),
+		ParseTime:            true,
+		UseDecimal:           true,
+		ReadTimeout:          cfg.ReadTimeout,
+		HeartbeatPeriod:      cfg.getHeartbeatPeriod(),
+		MaxReconnectAttempts: cfg.MaxReconnectAttempts,
+	}
+	if cfg.Socket != "" {
+		// go-mysql dials unix socket if the address contains '/'.
+		ret.Host = cfg.Socket
+		ret.Port = 0
 	}
+	return ret
 }
 
-// Client opens mysql db.
+// Client opens mysql db with pool settings applied.
 func (cfg *Config) Client() (*sql.DB, error) {
-	return sql.Open("mysql", cfg.ToDriverCfg().FormatDSN())
+	driverCfg, err := cfg.driverCfg()
+	if err != nil {
+		return nil, err
+	}
+	var connector driver.Connector
+	if cfg.PasswordProvider != nil {
+		connector = &passwordConnector{
+			driverCfg: driverCfg,
+			provider:  cfg.PasswordProvider,
+		}
+	} else {
+		connector, err = mysql.NewConnector(driverCfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	db := sql.OpenDB(connector)
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return db, nil
 }
 
 func (cfg *Config) getCharset() string {
@@ -73,3 +279,37 @@ func (cfg *Config) getCharset() string {
 	}
 	return "utf8mb4"
 }
+
+func (cfg *Config) getTimeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return cfg.Timeout
+	}
+	return DefaultTimeout
+}
+
+func (cfg *Config) getHeartbeatPeriod() time.Duration {
+	if cfg.HeartbeatPeriod > 0 {
+		return cfg.HeartbeatPeriod
+	}
+	return cfg.ReadTimeout / 2
+}
+
+func (cfg *Config) getTLSConfig() *tls.Config {
+	switch mode := strings.ToLower(cfg.TLS); mode {
+	case "", "false", "0", "f":
+		return nil
+	case "true", "1", "t":
+		return &tls.Config{ServerName: cfg.Host}
+	case "skip-verify", "preferred":
+		// NOTE: go-mysql does not support fallback to plain connection.
+		return &tls.Config{InsecureSkipVerify: true}
+	default:
+		tlsConfigsMu.RLock()
+		config, ok := tlsConfigs[cfg.TLS]
+		tlsConfigsMu.RUnlock()
+		if !ok {
+			panic(fmt.Errorf("ToBinlogSyncerCfg: TLS config %q not registered", cfg.TLS))
+		}
+		return config.Clone()
+	}
+}
@@ -14,6 +14,8 @@
 //     - `--binlog-row-image=FULL`: before and after image of row changes
 //     - `--binlog-row-metadata=FULL`: extra optional meta for tables such as signedness for numeric columns/column names ...
 //
+// Use Preflight to check these prerequisites (and privileges) at deploy time.
+//
 // ref:
 //   - https://mysqlhighavailability.com/more-metadata-is-written-into-binary-log/
 //   - https://mysqlhighavailability.com/taking-advantage-of-new-transaction-length-metadata/
@@ -26,6 +28,11 @@
 //   - BINARY fields are returned as string, but may have different trailing '\x00'.
 //   - JSON fields are returned as string, but elements inside may have different position.
 //
-//   - TIME with fraction seems have some bugs in incrdump too.
-//   - GEOMETRY fields are not tested
+// TIME fields are returned as time.Duration (or string in "[-]HH:MM:SS[.fraction]" format if
+// TimeAsString option is set) in both. GEOMETRY fields are returned as Geometry (SRID + WKB) in both.
+//
+// With canonical mode (fulldump.QueryOptions.Canonical/incrdump.Options.Canonical), DECIMAL/NUMERIC
+// fields are scaled to the declared scale, BINARY fields are padded to the declared length and JSON fields
+// are re-serialized by CanonicalJSON in both, so that values of the same row are identical, except JSON
+// doubles with integral value: incrdump can't tell them from integers and returns e.g. 1 for 1.0.
 package mycanal
@@ -0,0 +1,365 @@
+package mycanal
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPort = 3306
+)
+
+// ParseDSN parses a go-sql-driver/mysql style DSN:
+//
+//	[user[:password]@][tcp[(host[:port])]|unix(socket)]/[dbname][?param1=value1&...]
+//
+// Params named after Config fields (in camel case, e.g. charset, serverId, readTimeout, tls, socket, maxOpenConns)
+// set the fields, others are stored in Config.Params. dbname is ignored since mycanal works on the whole server.
+//
+// NOTE: The password never shows up in returned errors.
+func ParseDSN(dsn string) (*Config, error) {
+	driverCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, errors.WithMessage(err, "mycanal.ParseDSN")
+	}
+
+	cfg := &Config{
+		User:     driverCfg.User,
+		Password: driverCfg.Passwd,
+	}
+	switch driverCfg.Net {
+	case "tcp":
+		if err := cfg.setAddr(driverCfg.Addr); err != nil {
+			return nil, errors.WithMessage(err, "mycanal.ParseDSN")
+		}
+	case "unix":
+		cfg.Socket = driverCfg.Addr
+	default:
+		return nil, errors.Errorf("mycanal.ParseDSN: unsupported network %q", driverCfg.Net)
+	}
+
+	// Params are parsed here again to separate Config fields from driver params.
+	params := ""
+	if i := strings.LastIndex(dsn, "?"); i >= 0 && i > strings.LastIndex(dsn, "/") {
+		params = dsn[i+1:]
+	}
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return nil, errors.WithMessage(err, "mycanal.ParseDSN")
+	}
+	if err := cfg.setParams(values); err != nil {
+		return nil, errors.WithMessage(err, "mycanal.ParseDSN")
+	}
+	if _, err := cfg.driverCfg(); err != nil {
+		return nil, errors.WithMessage(err, "mycanal.ParseDSN")
+	}
+	return cfg, nil
+}
+
+// ParseURL parses an URL:
+//
+//	mysql://[user[:password]@][host[:port]][/dbname][?param1=value1&...]
+//
+// Params and dbname are the same as ParseDSN. Use the socket param for unix socket.
+//
+// NOTE: The password never shows up in returned errors.
+func ParseURL(rawURL string) (*Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// NOTE: url.Error contains the whole url.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, errors.WithMessage(err, "mycanal.ParseURL")
+	}
+	if u.Scheme != "mysql" {
+		return nil, errors.Errorf("mycanal.ParseURL: unsupported scheme %q", u.Scheme)
+	}
+
+	cfg := &Config{}
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	if err := cfg.setParams(u.Query()); err != nil {
+		return nil, errors.WithMessage(err, "mycanal.ParseURL")
+	}
+	if cfg.Socket == "" || u.Host != "" {
+		if err := cfg.setAddr(u.Host); err != nil {
+			return nil, errors.WithMessage(err, "mycanal.ParseURL")
+		}
+	}
+	if _, err := cfg.driverCfg(); err != nil {
+		return nil, errors.WithMessage(err, "mycanal.ParseURL")
+	}
+	return cfg, nil
+}
+
+// FromEnv loads config from environment variables with the given prefix (e.g. "MYSQL_"):
+//
+//   - <prefix>URL or <prefix>DSN: parsed by ParseURL/ParseDSN as the base config if present
+//   - <prefix>HOST, <prefix>PORT, <prefix>USER, <prefix>PASSWORD and <prefix><FIELD> for other fields in
+//     upper snake case (e.g. <prefix>SERVER_ID, <prefix>READ_TIMEOUT, <prefix>MAX_OPEN_CONNS): override fields if present
+//   - <prefix>PARAMS: driver params in query string format (e.g. "sql_mode=TRADITIONAL&maxAllowedPacket=0"),
+//     merged into Config.Params
+//
+// Durations are in time.ParseDuration format.
+func FromEnv(prefix string) (*Config, error) {
+	cfg := &Config{}
+	if v, ok := os.LookupEnv(prefix + "URL"); ok {
+		c, err := ParseURL(v)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "mycanal.FromEnv %sURL", prefix)
+		}
+		cfg = c
+	} else if v, ok := os.LookupEnv(prefix + "DSN"); ok {
+		c, err := ParseDSN(v)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "mycanal.FromEnv %sDSN", prefix)
+		}
+		cfg = c
+	}
+
+	for _, field := range []struct {
+		name  string
+		param string
+	}{
+		{"HOST", ""},
+		{"PORT", ""},
+		{"USER", ""},
+		{"PASSWORD", ""},
+		{"CHARSET", "charset"},
+		{"SERVER_ID", "serverId"},
+		{"TIMEOUT", "timeout"},
+		{"READ_TIMEOUT", "readTimeout"},
+		{"WRITE_TIMEOUT", "writeTimeout"},
+		{"TLS", "tls"},
+		{"SOCKET", "socket"},
+		{"HEARTBEAT_PERIOD", "heartbeatPeriod"},
+		{"MAX_RECONNECT_ATTEMPTS", "maxReconnectAttempts"},
+		{"MAX_OPEN_CONNS", "maxOpenConns"},
+		{"MAX_IDLE_CONNS", "maxIdleConns"},
+		{"CONN_MAX_LIFETIME", "connMaxLifetime"},
+		{"CONN_MAX_IDLE_TIME", "connMaxIdleTime"},
+		{"UTC_SESSION", "utcSession"},
+		{"PARAMS", ""},
+	} {
+		v, ok := os.LookupEnv(prefix + field.name)
+		if !ok {
+			continue
+		}
+		var err error
+		switch field.name {
+		case "HOST":
+			cfg.Host = v
+		case "PORT":
+			err = cfg.setPort(v)
+		case "USER":
+			cfg.User = v
+		case "PASSWORD":
+			cfg.Password = v
+		case "PARAMS":
+			var values url.Values
+			values, err = url.ParseQuery(v)
+			if err == nil {
+				for name, vals := range values {
+					cfg.setDriverParam(name, vals[len(vals)-1])
+				}
+			}
+		default:
+			err = cfg.setParam(field.param, v)
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "mycanal.FromEnv %s%s", prefix, field.name)
+		}
+	}
+
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	if _, err := cfg.driverCfg(); err != nil {
+		return nil, errors.WithMessagef(err, "mycanal.FromEnv")
+	}
+	return cfg, nil
+}
+
+// URL returns the config in ParseURL format, including the password.
+func (cfg *Config) URL() string {
+	return cfg.toURL().String()
+}
+
+// String returns the config in ParseURL format with password redacted, for logging.
+func (cfg *Config) String() string {
+	u := cfg.toURL()
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(cfg.User, "xxxxx")
+	}
+	return u.String()
+}
+
+func (cfg *Config) toURL() *url.URL {
+	u := &url.URL{
+		Scheme: "mysql",
+		Path:   "/",
+	}
+	if cfg.Socket == "" {
+		u.Host = net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port)))
+	}
+	if cfg.Password != "" {
+		u.User = url.UserPassword(cfg.User, cfg.Password)
+	} else {
+		u.User = url.User(cfg.User)
+	}
+
+	values := url.Values{}
+	if cfg.Charset != "" {
+		values.Set("charset", cfg.Charset)
+	}
+	if cfg.ServerId != 0 {
+		values.Set("serverId", strconv.FormatUint(uint64(cfg.ServerId), 10))
+	}
+	if cfg.Timeout != 0 {
+		values.Set("timeout", cfg.Timeout.String())
+	}
+	if cfg.ReadTimeout != 0 {
+		values.Set("readTimeout", cfg.ReadTimeout.String())
+	}
+	if cfg.WriteTimeout != 0 {
+		values.Set("writeTimeout", cfg.WriteTimeout.String())
+	}
+	if cfg.TLS != "" {
+		values.Set("tls", cfg.TLS)
+	}
+	if cfg.Socket != "" {
+		values.Set("socket", cfg.Socket)
+	}
+	if cfg.HeartbeatPeriod != 0 {
+		values.Set("heartbeatPeriod", cfg.HeartbeatPeriod.String())
+	}
+	if cfg.MaxReconnectAttempts != 0 {
+		values.Set("maxReconnectAttempts", strconv.Itoa(cfg.MaxReconnectAttempts))
+	}
+	if cfg.MaxOpenConns != 0 {
+		values.Set("maxOpenConns", strconv.Itoa(cfg.MaxOpenConns))
+	}
+	if cfg.MaxIdleConns != 0 {
+		values.Set("maxIdleConns", strconv.Itoa(cfg.MaxIdleConns))
+	}
+	if cfg.ConnMaxLifetime != 0 {
+		values.Set("connMaxLifetime", cfg.ConnMaxLifetime.String())
+	}
+	if cfg.ConnMaxIdleTime != 0 {
+		values.Set("connMaxIdleTime", cfg.ConnMaxIdleTime.String())
+	}
+	if cfg.UTCSession {
+		values.Set("utcSession", "true")
+	}
+	for name, value := range cfg.Params {
+		values.Set(name, value)
+	}
+	u.RawQuery = values.Encode()
+	return u
+}
+
+func (cfg *Config) setAddr(addr string) error {
+	if addr == "" {
+		return errors.New("missing host")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port.
+		cfg.Host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		cfg.Port = defaultPort
+		return nil
+	}
+	cfg.Host = host
+	if port == "" {
+		cfg.Port = defaultPort
+		return nil
+	}
+	return cfg.setPort(port)
+}
+
+func (cfg *Config) setPort(port string) error {
+	v, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return errors.Errorf("invalid port %q", port)
+	}
+	cfg.Port = uint16(v)
+	return nil
+}
+
+func (cfg *Config) setParams(values url.Values) error {
+	for name, vals := range values {
+		if err := cfg.setParam(name, vals[len(vals)-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) setParam(name, value string) (err error) {
+	switch name {
+	case "charset":
+		cfg.Charset = value
+
+	case "serverId":
+		var v uint64
+		v, err = strconv.ParseUint(value, 10, 32)
+		cfg.ServerId = uint32(v)
+
+	case "timeout":
+		cfg.Timeout, err = time.ParseDuration(value)
+
+	case "readTimeout":
+		cfg.ReadTimeout, err = time.ParseDuration(value)
+
+	case "writeTimeout":
+		cfg.WriteTimeout, err = time.ParseDuration(value)
+
+	case "tls":
+		cfg.TLS = value
+
+	case "socket":
+		cfg.Socket = value
+
+	case "heartbeatPeriod":
+		cfg.HeartbeatPeriod, err = time.ParseDuration(value)
+
+	case "maxReconnectAttempts":
+		cfg.MaxReconnectAttempts, err = strconv.Atoi(value)
+
+	case "maxOpenConns":
+		cfg.MaxOpenConns, err = strconv.Atoi(value)
+
+	case "maxIdleConns":
+		cfg.MaxIdleConns, err = strconv.Atoi(value)
+
+	case "connMaxLifetime":
+		cfg.ConnMaxLifetime, err = time.ParseDuration(value)
+
+	case "connMaxIdleTime":
+		cfg.ConnMaxIdleTime, err = time.ParseDuration(value)
+
+	case "utcSession":
+		cfg.UTCSession, err = strconv.ParseBool(value)
+
+	default:
+		cfg.setDriverParam(name, value)
+	}
+	return errors.WithMessagef(err, "invalid %s", name)
+}
+
+func (cfg *Config) setDriverParam(name, value string) {
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params[name] = value
+}
@@ -0,0 +1,96 @@
+package dumpfile
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+// Column describes a column in dump files.
+type Column struct {
+	// Name is the column name.
+	Name string `json:"name"`
+
+	// DataType is information_schema.COLUMNS.DATA_TYPE, e.g. "int", "varbinary".
+	DataType string `json:"data_type"`
+
+	// ColumnType is information_schema.COLUMNS.COLUMN_TYPE, e.g. "int unsigned", "varbinary(16)".
+	ColumnType string `json:"column_type"`
+}
+
+const (
+	nullText = `\N`
+)
+
+// Binary returns true if values of the column are binary strings.
+func (col *Column) Binary() bool {
+	switch col.DataType {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit",
+		"geometry", "point", "linestring", "polygon",
+		"multipoint", "multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return true
+	}
+	return false
+}
+
+// encode converts a value returned by fulldump to text. number is true if the text is a numeric literal.
+func (col *Column) encode(v interface{}) (text string, number bool, err error) {
+	switch val := v.(type) {
+	case int8:
+		return strconv.FormatInt(int64(val), 10), true, nil
+	case int16:
+		return strconv.FormatInt(int64(val), 10), true, nil
+	case int32:
+		return strconv.FormatInt(int64(val), 10), true, nil
+	case int64:
+		return strconv.FormatInt(val, 10), true, nil
+	case uint8:
+		return strconv.FormatUint(uint64(val), 10), true, nil
+	case uint16:
+		return strconv.FormatUint(uint64(val), 10), true, nil
+	case uint32:
+		return strconv.FormatUint(uint64(val), 10), true, nil
+	case uint64:
+		return strconv.FormatUint(val, 10), true, nil
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32), true, nil
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64), true, nil
+	case string:
+		if col.Binary() {
+			return base64.StdEncoding.EncodeToString([]byte(val)), false, nil
+		}
+		return val, false, nil
+	case []byte:
+		if col.Binary() {
+			return base64.StdEncoding.EncodeToString(val), false, nil
+		}
+		return string(val), false, nil
+	case Geometry:
+		return base64.StdEncoding.EncodeToString(val.Bytes()), false, nil
+	case time.Duration:
+		return FormatTime(val, 6), false, nil
+	case time.Time:
+		if col.DataType == "date" {
+			return val.Format("2006-01-02"), false, nil
+		}
+		return val.Format("2006-01-02 15:04:05.999999"), false, nil
+	default:
+		return "", false, fmt.Errorf("dumpfile: unsupported value type %T for column %q", v, col.Name)
+	}
+}
+
+// decode converts text back to a value which can be used as query argument.
+func (col *Column) decode(text string) (interface{}, error) {
+	if col.Binary() {
+		ret, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("dumpfile: bad base64 value for column %q: %s", col.Name, err)
+		}
+		return ret, nil
+	}
+	return text, nil
+}
@@ -0,0 +1,11 @@
+// Package dumpfile exports a consistent snapshot (see fulldump) into plain files and loads them back.
+//
+// An export directory contains one file per table (NDJSON or RFC4180 CSV) and a manifest
+// (ManifestFile) with the GTID set of the snapshot, column types and row counts.
+//
+// Encoding is type-preserving so that a round trip is lossless:
+//   - Numeric values are written in full precision (including BIGINT UNSIGNED).
+//   - Binary values (BINARY/VARBINARY/BLOB/BIT/GEOMETRY) are base64 encoded.
+//   - NULL is JSON null in NDJSON and `\N` in CSV, string values starting with `\` are written
+//     with an extra leading `\` in CSV so that NULL and strings (including empty string) are distinguishable.
+package dumpfile
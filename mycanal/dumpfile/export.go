@@ -0,0 +1,198 @@
+package dumpfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+var (
+	// DefaultFormat is the default value of ExportOptions.Format.
+	DefaultFormat = FormatNDJSON
+)
+
+// ExportOptions is options used in Export.
+type ExportOptions struct {
+	// Format of table files.
+	//
+	// Use DefaultFormat if not set.
+	Format Format
+
+	// Tables selects tables to export. nil to select all tables (see fulldump.ListTables).
+	Tables *fulldump.TableFilter
+
+	// Dump is the options used to take the snapshot, optional. Dump.ColumnRules (if any) is applied to
+	// exported rows: dropped columns are not exported. Other value options (Canonical, TimeAsString
+	// and ValueMapper) are ignored since files are loaded back by Load.
+	Dump *fulldump.Options
+}
+
+// Export takes a consistent snapshot and writes selected tables into dir (created if not exists),
+// one file per table, and then the manifest. Generated columns are not exported.
+func Export(ctx context.Context, cfg *Config, dir string, opts *ExportOptions) (*Manifest, error) {
+
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = DefaultFormat
+	}
+	if _, err := NewWriter(format, nil, nil); err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.Export")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.Export mkdir error")
+	}
+
+	manifest := &Manifest{
+		Format: format,
+		Tables: []*TableManifest{},
+	}
+
+	// Column rules are applied in exportTable.
+	var (
+		dumpOpts *fulldump.Options
+		rules    *ColumnRules
+	)
+	if opts.Dump != nil {
+		o := *opts.Dump
+		rules = o.ColumnRules
+		o.ColumnRules = nil
+		dumpOpts = &o
+	}
+
+	gtidSet, err := fulldump.FullDumpOpts(ctx, cfg, dumpOpts, func(ctx context.Context, q sqlh.Queryer) error {
+		tables, err := fulldump.ListTables(ctx, q, opts.Tables)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			tm, err := exportTable(ctx, q, dir, format, table.TableRef, rules)
+			if err != nil {
+				return err
+			}
+			manifest.Tables = append(manifest.Tables, tm)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.Export")
+	}
+	manifest.GTIDSet = gtidSet
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.Export")
+	}
+	return manifest, nil
+}
+
+func exportTable(ctx context.Context, q sqlh.Queryer, dir string, format Format, table fulldump.TableRef, rules *ColumnRules) (tm *TableManifest, err error) {
+
+	columns, err := tableColumns(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+	if tableRules := rules.ForTable(table.Schema, table.Table); tableRules != nil {
+		kept := columns[:0]
+		for _, col := range columns {
+			if !tableRules.Drop(col.Name) {
+				kept = append(kept, col)
+			}
+		}
+		columns = kept
+	}
+
+	tm = &TableManifest{
+		Schema:  table.Schema,
+		Table:   table.Table,
+		File:    tableFileName(table.Schema, table.Table, format.Ext()),
+		Columns: columns,
+	}
+
+	f, err := os.Create(filepath.Join(dir, tm.File))
+	if err != nil {
+		return nil, errors.WithMessage(err, "dumpfile create file error")
+	}
+	defer func() {
+		if e := f.Close(); e != nil && err == nil {
+			err = errors.WithMessage(e, "dumpfile close file error")
+		}
+	}()
+
+	w, err := NewWriter(format, f, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// All columns dropped: an empty file, which is skipped by Load.
+	if len(columns) == 0 {
+		return tm, errors.WithMessagef(w.Flush(), "dumpfile write table %s error", table.Quoted())
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	_, iter, err := (&fulldump.TableQuery{
+		Table:       table,
+		Columns:     names,
+		ColumnRules: rules,
+	}).QueryValues(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer iter(false)
+
+	for {
+		values, err := iter(true)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			break
+		}
+		if err := w.WriteRow(values); err != nil {
+			return nil, errors.WithMessagef(err, "dumpfile write table %s error", table.Quoted())
+		}
+		tm.Rows++
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, errors.WithMessagef(err, "dumpfile write table %s error", table.Quoted())
+	}
+	return tm, nil
+}
+
+func tableColumns(ctx context.Context, q sqlh.Queryer, table fulldump.TableRef) ([]Column, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		"SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE FROM information_schema.COLUMNS "+
+			"WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND EXTRA NOT LIKE '%VIRTUAL GENERATED%' AND EXTRA NOT LIKE '%STORED GENERATED%' "+
+			"ORDER BY ORDINAL_POSITION",
+		table.Schema,
+		table.Table,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "dumpfile query columns error")
+	}
+	defer rows.Close()
+
+	ret := []Column{}
+	for rows.Next() {
+		col := Column{}
+		if err := rows.Scan(&col.Name, &col.DataType, &col.ColumnType); err != nil {
+			return nil, errors.WithMessage(err, "dumpfile scan columns error")
+		}
+		ret = append(ret, col)
+	}
+	return ret, errors.WithMessage(rows.Err(), "dumpfile query columns error")
+}
@@ -0,0 +1,258 @@
+package dumpfile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the file format.
+type Format string
+
+const (
+	// FormatNDJSON is newline delimited JSON, one object (column name -> value) per line.
+	FormatNDJSON Format = "ndjson"
+
+	// FormatCSV is RFC4180 CSV with a header line of column names.
+	FormatCSV Format = "csv"
+)
+
+// RowWriter writes rows of a table.
+type RowWriter interface {
+	// WriteRow writes a row, values are aligned with columns and are of types returned by fulldump.
+	WriteRow(values []interface{}) error
+
+	// Flush flushes buffered data. It must be called after all rows written.
+	Flush() error
+}
+
+// RowReader reads rows of a table.
+type RowReader interface {
+	// ReadRow reads a row, values are aligned with columns and can be used as query arguments:
+	// nil for NULL, []byte for binary columns and string otherwise.
+	// It returns io.EOF if no more row.
+	ReadRow() ([]interface{}, error)
+}
+
+// NewWriter creates a RowWriter of the format.
+func NewWriter(format Format, w io.Writer, columns []Column) (RowWriter, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("dumpfile: unknown format %q", format)
+	}
+}
+
+// NewReader creates a RowReader of the format.
+func NewReader(format Format, r io.Reader, columns []Column) (RowReader, error) {
+	switch format {
+	case FormatNDJSON:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		return &ndjsonReader{dec: dec, columns: columns}, nil
+	case FormatCSV:
+		return &csvReader{r: csv.NewReader(r), columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("dumpfile: unknown format %q", format)
+	}
+}
+
+// Ext returns the file extension of the format.
+func (format Format) Ext() string {
+	return "." + string(format)
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	buf     []byte
+}
+
+func (w *ndjsonWriter) WriteRow(values []interface{}) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("dumpfile: expect %d values but got %d", len(w.columns), len(values))
+	}
+
+	buf := append(w.buf[:0], '{')
+	for i := range w.columns {
+		col := &w.columns[i]
+		if i != 0 {
+			buf = append(buf, ',')
+		}
+		name, _ := json.Marshal(col.Name)
+		buf = append(buf, name...)
+		buf = append(buf, ':')
+
+		if values[i] == nil {
+			buf = append(buf, "null"...)
+			continue
+		}
+		text, number, err := col.encode(values[i])
+		if err != nil {
+			return err
+		}
+		if number {
+			buf = append(buf, text...)
+			continue
+		}
+		str, err := json.Marshal(text)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, str...)
+	}
+	buf = append(buf, '}', '\n')
+	w.buf = buf
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.w.Flush()
+}
+
+type ndjsonReader struct {
+	dec     *json.Decoder
+	columns []Column
+}
+
+func (r *ndjsonReader) ReadRow() ([]interface{}, error) {
+	obj := map[string]interface{}{}
+	if err := r.dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	ret := make([]interface{}, len(r.columns))
+	for i := range r.columns {
+		col := &r.columns[i]
+		v, ok := obj[col.Name]
+		if !ok {
+			return nil, fmt.Errorf("dumpfile: missing column %q", col.Name)
+		}
+		switch val := v.(type) {
+		case nil:
+		case json.Number:
+			ret[i] = val.String()
+		case string:
+			decoded, err := col.decode(val)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = decoded
+		default:
+			return nil, fmt.Errorf("dumpfile: unexpected json value %T for column %q", v, col.Name)
+		}
+	}
+	return ret, nil
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	columns     []Column
+	record      []string
+	wroteHeader bool
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+	w.wroteHeader = true
+	header := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		header[i] = col.Name
+	}
+	return w.w.Write(header)
+}
+
+func (w *csvWriter) WriteRow(values []interface{}) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("dumpfile: expect %d values but got %d", len(w.columns), len(values))
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	record := w.record[:0]
+	for i := range w.columns {
+		if values[i] == nil {
+			record = append(record, nullText)
+			continue
+		}
+		text, _, err := w.columns[i].encode(values[i])
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(text, `\`) {
+			text = `\` + text
+		}
+		record = append(record, text)
+	}
+	w.record = record
+
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvReader struct {
+	r          *csv.Reader
+	columns    []Column
+	readHeader bool
+}
+
+func (r *csvReader) ReadRow() ([]interface{}, error) {
+	if !r.readHeader {
+		header, err := r.r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if len(header) != len(r.columns) {
+			return nil, fmt.Errorf("dumpfile: expect %d columns in csv header but got %d", len(r.columns), len(header))
+		}
+		for i, name := range header {
+			if name != r.columns[i].Name {
+				return nil, fmt.Errorf("dumpfile: expect column %q in csv header but got %q", r.columns[i].Name, name)
+			}
+		}
+		r.readHeader = true
+	}
+
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]interface{}, len(r.columns))
+	for i := range r.columns {
+		text := record[i]
+		if text == nullText {
+			continue
+		}
+		if strings.HasPrefix(text, `\`) {
+			text = text[1:]
+		}
+		v, err := r.columns[i].decode(text)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
@@ -0,0 +1,61 @@
+package dumpfile
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+
+	assert := assert.New(t)
+
+	columns := []Column{
+		{Name: "u64", DataType: "bigint", ColumnType: "bigint unsigned"},
+		{Name: "f", DataType: "float", ColumnType: "float"},
+		{Name: "s", DataType: "varchar", ColumnType: "varchar(16)"},
+		{Name: "b", DataType: "varbinary", ColumnType: "varbinary(16)"},
+		{Name: "d", DataType: "date", ColumnType: "date"},
+		{Name: "dt", DataType: "datetime", ColumnType: "datetime(6)"},
+		{Name: "t", DataType: "time", ColumnType: "time(3)"},
+	}
+	dt := time.Date(2020, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	rows := [][]interface{}{
+		{uint64(math.MaxUint64), float32(1.1), "", "\x00\xff", dt, dt, -time.Hour - 500*time.Millisecond},
+		{nil, nil, nil, nil, nil, nil, nil},
+		{uint64(0), float32(-3.5), `\N`, "", dt, dt, time.Duration(0)},
+		{uint64(1), float32(0), "a,\"b\"\nc", "\\", dt, dt, time.Second},
+	}
+	expect := [][]interface{}{
+		{"18446744073709551615", "1.1", "", []byte("\x00\xff"), "2020-01-02", "2020-01-02 03:04:05.123456", "-01:00:00.500000"},
+		{nil, nil, nil, nil, nil, nil, nil},
+		{"0", "-3.5", `\N`, []byte{}, "2020-01-02", "2020-01-02 03:04:05.123456", "00:00:00.000000"},
+		{"1", "0", "a,\"b\"\nc", []byte("\\"), "2020-01-02", "2020-01-02 03:04:05.123456", "00:00:01.000000"},
+	}
+
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		buf := &bytes.Buffer{}
+		w, err := NewWriter(format, buf, columns)
+		assert.NoError(err)
+		for _, row := range rows {
+			assert.NoError(w.WriteRow(row))
+		}
+		assert.NoError(w.Flush())
+
+		r, err := NewReader(format, buf, columns)
+		assert.NoError(err)
+		for i := range expect {
+			row, err := r.ReadRow()
+			assert.NoError(err, "format %s row %d", format, i)
+			assert.Equal(expect[i], row, "format %s row %d", format, i)
+		}
+		_, err = r.ReadRow()
+		assert.Equal(io.EOF, err, "format %s", format)
+	}
+
+}
@@ -0,0 +1,131 @@
+package dumpfile
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+var (
+	// DefaultBatchSize is the default value of LoadOptions.BatchSize.
+	DefaultBatchSize = 500
+)
+
+const (
+	// Max number of placeholders in a prepared statement.
+	maxPlaceholders = 65535
+)
+
+// LoadOptions is options used in Load.
+type LoadOptions struct {
+	// BatchSize is the max number of rows in an INSERT statement.
+	//
+	// Use DefaultBatchSize if not set.
+	BatchSize int
+
+	// Schema overrides the target database of all tables if not empty.
+	Schema string
+}
+
+// Load bulk-inserts tables in an export directory (see Export) into q. Target tables must exist.
+//
+// It's recommended to use a *sql.Tx as q so that it's all or nothing.
+func Load(ctx context.Context, q sqlh.Queryer, dir string, opts *LoadOptions) (*Manifest, error) {
+
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.Load")
+	}
+
+	for _, tm := range manifest.Tables {
+		target := fulldump.TableRef{Schema: tm.Schema, Table: tm.Table}
+		if opts.Schema != "" {
+			target.Schema = opts.Schema
+		}
+		if err := loadTable(ctx, q, dir, manifest.Format, tm, target, opts.batchSize(len(tm.Columns))); err != nil {
+			return nil, errors.WithMessagef(err, "dumpfile.Load table %s", target.Quoted())
+		}
+	}
+	return manifest, nil
+}
+
+func loadTable(ctx context.Context, q sqlh.Queryer, dir string, format Format, tm *TableManifest, target fulldump.TableRef, batchSize int) error {
+
+	if len(tm.Columns) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, tm.File))
+	if err != nil {
+		return errors.WithMessage(err, "open file error")
+	}
+	defer f.Close()
+
+	r, err := NewReader(format, f, tm.Columns)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(tm.Columns))
+	for i, col := range tm.Columns {
+		names[i] = col.Name
+	}
+	prefix := "INSERT INTO " + target.Quoted() + " (" + fulldump.QuoteIdents(names) + ") VALUES "
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ") + ")"
+
+	var (
+		args []interface{}
+		n    int
+	)
+	flush := func() error {
+		if n == 0 {
+			return nil
+		}
+		query := prefix + strings.TrimSuffix(strings.Repeat(tuple+", ", n), ", ")
+		_, err := q.ExecContext(ctx, query, args...)
+		args = args[:0]
+		n = 0
+		return errors.WithMessage(err, "insert error")
+	}
+
+	for {
+		values, err := r.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "read file %q error", tm.File)
+		}
+
+		args = append(args, values...)
+		n++
+		if n >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+func (opts *LoadOptions) batchSize(numColumns int) int {
+	ret := DefaultBatchSize
+	if opts.BatchSize > 0 {
+		ret = opts.BatchSize
+	}
+	if numColumns > 0 && ret > maxPlaceholders/numColumns {
+		ret = maxPlaceholders / numColumns
+	}
+	return ret
+}
@@ -0,0 +1,109 @@
+package dumpfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestFile is the file name of manifest in an export directory.
+	ManifestFile = "manifest.json"
+)
+
+// Manifest describes an export directory.
+type Manifest struct {
+	// GTIDSet is the gtid set of the snapshot.
+	GTIDSet string `json:"gtid_set"`
+
+	// Format is the format of table files.
+	Format Format `json:"format"`
+
+	// Tables are the exported tables.
+	Tables []*TableManifest `json:"tables"`
+}
+
+// TableManifest describes an exported table.
+type TableManifest struct {
+	// Schema is the database name.
+	Schema string `json:"schema"`
+
+	// Table is the table name.
+	Table string `json:"table"`
+
+	// File is the file name (relative to the export directory) of the table, see tableFileName.
+	File string `json:"file"`
+
+	// Columns are the columns in the file.
+	Columns []Column `json:"columns"`
+
+	// Rows is the number of rows in the file.
+	Rows int64 `json:"rows"`
+}
+
+// ReadManifest reads the manifest of an export directory. It returns error if any table file is not
+// inside the directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.ReadManifest read error")
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, errors.WithMessage(err, "dumpfile.ReadManifest unmarshal error")
+	}
+	for _, tm := range manifest.Tables {
+		if !isLocalPath(tm.File) {
+			return nil, errors.Errorf("dumpfile.ReadManifest file %q of table %s.%s is not inside the export directory", tm.File, tm.Schema, tm.Table)
+		}
+	}
+	return manifest, nil
+}
+
+// tableFileName returns the file name of a table in an export directory: "<schema>.<table><ext>" with
+// bytes other than ASCII letters, digits, '_', '-' and '$' in names percent-encoded (e.g. "a.b" ->
+// "a%2Eb"), so that it's always inside the directory and distinct for different tables.
+func tableFileName(schema, table, ext string) string {
+	return escapeFileName(schema) + "." + escapeFileName(table) + ext
+}
+
+func escapeFileName(name string) string {
+	buf := &strings.Builder{}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '$':
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// isLocalPath reports whether a relative path (in either '/' or OS specific separator) is inside
+// the directory it's relative to.
+func isLocalPath(path string) bool {
+	if path == "" || filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return false
+	}
+	path = filepath.Clean(filepath.FromSlash(path))
+	sep := string(filepath.Separator)
+	return path != "." && path != ".." && !strings.HasPrefix(path, sep) && !strings.HasPrefix(path, ".."+sep)
+}
+
+func writeManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.WithMessage(err, "dumpfile marshal manifest error")
+	}
+	return errors.WithMessage(
+		ioutil.WriteFile(filepath.Join(dir, ManifestFile), data, 0644),
+		"dumpfile write manifest error",
+	)
+}
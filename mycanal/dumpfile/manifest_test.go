@@ -0,0 +1,69 @@
+package dumpfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableFileName(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, testCase := range []struct {
+		Schema string
+		Table  string
+		Expect string
+	}{
+		{"db", "user_1", "db.user_1.ndjson"},
+		{"a.b", "c", "a%2Eb.c.ndjson"},
+		{"a", "b.c", "a.b%2Ec.ndjson"},
+		{"..", "x/y", "%2E%2E.x%2Fy.ndjson"},
+		{"a%2E", "表", "a%252E.%E8%A1%A8.ndjson"},
+	} {
+		name := tableFileName(testCase.Schema, testCase.Table, ".ndjson")
+		assert.Equal(testCase.Expect, name)
+		assert.True(isLocalPath(name))
+	}
+
+	for _, testCase := range []struct {
+		Path   string
+		Expect bool
+	}{
+		{"a.csv", true},
+		{"a/../b.csv", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../a.csv", false},
+		{"a/../../b.csv", false},
+		{"/etc/passwd", false},
+	} {
+		assert.Equal(testCase.Expect, isLocalPath(testCase.Path), "path %q", testCase.Path)
+	}
+}
+
+func TestReadManifestFile(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "dumpfile")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	manifest := &Manifest{
+		Format: FormatNDJSON,
+		Tables: []*TableManifest{{Schema: "db", Table: "t", File: "db.t.ndjson"}},
+	}
+	assert.NoError(writeManifest(dir, manifest))
+	_, err = ReadManifest(dir)
+	assert.NoError(err)
+
+	manifest.Tables[0].File = filepath.Join("..", "t.ndjson")
+	assert.NoError(writeManifest(dir, manifest))
+	_, err = ReadManifest(dir)
+	assert.Error(err)
+}
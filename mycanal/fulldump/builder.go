@@ -0,0 +1,300 @@
+package fulldump
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+// TableRef identifies a table.
+type TableRef struct {
+	// Schema is the database name.
+	Schema string
+
+	// Table is the table name.
+	Table string
+}
+
+// TableQuery builds a query on a table. Identifiers are backtick-escaped.
+type TableQuery struct {
+	// Table to query. Required.
+	Table TableRef
+
+	// Columns to select. Empty to select all columns.
+	Columns []string
+
+	// Where is an optional predicate with '?' placeholders, e.g. "tenant_id = ? AND updated_at > ?".
+	Where string
+
+	// Args for placeholders in Where.
+	Args []interface{}
+
+	// OrderByPK orders rows by primary key.
+	OrderByPK bool
+
+	// After selects rows whose primary key values are greater than it, optional.
+	After []interface{}
+
+	// Until selects rows whose primary key values are less than or equal to it, optional.
+	Until []interface{}
+
+	// Limit is the max number of rows, 0 for no limit.
+	Limit int
+
+	// Offset skips rows, used only if Limit > 0.
+	Offset int
+
+	// Canonical is the same as QueryOptions.Canonical.
+	Canonical bool
+
+	// TimeAsString is the same as QueryOptions.TimeAsString.
+	TimeAsString bool
+
+	// ValueMapper is the same as QueryOptions.ValueMapper.
+	ValueMapper *ValueMapper
+
+	// ColumnRules is the same as QueryOptions.ColumnRules.
+	ColumnRules *ColumnRules
+}
+
+// Quoted returns the backtick-escaped "schema.table".
+func (table TableRef) Quoted() string {
+	return QuoteIdent(table.Schema) + "." + QuoteIdent(table.Table)
+}
+
+// SQL builds the query and its args. pk is the primary key column names of the table (see PrimaryKey),
+// which is needed only if OrderByPK/After/Until is used.
+func (tq *TableQuery) SQL(pk []string) (query string, args []interface{}, err error) {
+
+	if tq.needPK() && len(pk) == 0 {
+		return "", nil, errors.Errorf("fulldump.TableQuery: table %s has no primary key", tq.Table.Quoted())
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("SELECT ")
+	if len(tq.Columns) == 0 {
+		b.WriteString("*")
+	} else {
+		b.WriteString(QuoteIdents(tq.Columns))
+	}
+	b.WriteString(" FROM ")
+	b.WriteString(tq.Table.Quoted())
+
+	conds := []string{}
+	if tq.Where != "" {
+		conds = append(conds, "("+tq.Where+")")
+		args = append(args, tq.Args...)
+	}
+	if tq.After != nil {
+		if len(tq.After) != len(pk) {
+			return "", nil, errors.Errorf("fulldump.TableQuery: expect %d values in After but got %d", len(pk), len(tq.After))
+		}
+		conds = append(conds, fmt.Sprintf("(%s) > (%s)", QuoteIdents(pk), placeholders(len(pk))))
+		args = append(args, tq.After...)
+	}
+	if tq.Until != nil {
+		if len(tq.Until) != len(pk) {
+			return "", nil, errors.Errorf("fulldump.TableQuery: expect %d values in Until but got %d", len(pk), len(tq.Until))
+		}
+		conds = append(conds, fmt.Sprintf("(%s) <= (%s)", QuoteIdents(pk), placeholders(len(pk))))
+		args = append(args, tq.Until...)
+	}
+	if len(conds) != 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(conds, " AND "))
+	}
+
+	if tq.OrderByPK {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(QuoteIdents(pk))
+	}
+
+	if tq.Limit > 0 {
+		fmt.Fprintf(b, " LIMIT %d", tq.Limit)
+		if tq.Offset > 0 {
+			fmt.Fprintf(b, " OFFSET %d", tq.Offset)
+		}
+	}
+
+	return b.String(), args, nil
+}
+
+// Query runs the query and returns RowIter.
+func (tq *TableQuery) Query(ctx context.Context, q sqlh.Queryer) (RowIter, error) {
+	names, valuesIter, err := tq.QueryValues(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return valuesToRowIter(names, valuesIter), nil
+}
+
+// QueryValues runs the query and returns column names and ValuesIter.
+func (tq *TableQuery) QueryValues(ctx context.Context, q sqlh.Queryer) ([]string, ValuesIter, error) {
+	var pk []string
+	if tq.needPK() {
+		var err error
+		pk, err = PrimaryKey(ctx, q, tq.Table)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	query, args, err := tq.SQL(pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return QueryValuesOpts(ctx, q, tq.queryOptions(), query, args...)
+}
+
+// Paginate iterates rows in primary key order with keyset pagination: each page (at most pageSize rows)
+// is a separate query starting after the last primary key of the previous page (or tq.After for
+// the first page). Limit/Offset/OrderByPK of tq are ignored. Primary key columns are appended to
+// Columns if missing.
+//
+// lastKey returns the primary key values of the last row returned by iter, which can be used as After to resume.
+// They are the values read from the database, not the ones in rows (which may be converted by
+// TimeAsString/ValueMapper/ColumnRules).
+func (tq *TableQuery) Paginate(ctx context.Context, q sqlh.Queryer, pageSize int) (iter RowIter, lastKey func() []interface{}, err error) {
+
+	if pageSize <= 0 {
+		return nil, nil, errors.Errorf("fulldump.TableQuery.Paginate: pageSize <= 0")
+	}
+
+	pk, err := PrimaryKey(ctx, q, tq.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pk) == 0 {
+		return nil, nil, errors.Errorf("fulldump.TableQuery.Paginate: table %s has no primary key", tq.Table.Quoted())
+	}
+
+	queryOpts := tq.queryOptions()
+	unsigned, err := queryOpts.unsignedColumns(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := *tq
+	page.OrderByPK = true
+	page.Limit = pageSize
+	page.Offset = 0
+	if len(page.Columns) != 0 {
+		page.Columns = append([]string{}, page.Columns...)
+		for _, name := range pk {
+			if !containsString(page.Columns, name) {
+				page.Columns = append(page.Columns, name)
+			}
+		}
+	}
+
+	var (
+		after   = tq.After
+		capture = &keyCapture{columns: pk}
+		cur     RowIter
+		n       int  // rows returned in current page
+		done    bool // no more page
+	)
+
+	iter = func(next bool) (map[string]interface{}, error) {
+		if !next {
+			if cur != nil {
+				_, err := cur(false)
+				cur = nil
+				return nil, err
+			}
+			return nil, nil
+		}
+
+		for {
+			if cur == nil {
+				if done {
+					return nil, nil
+				}
+				page.After = after
+				query, args, err := page.SQL(pk)
+				if err != nil {
+					return nil, err
+				}
+				names, valuesIter, err := queryValues(ctx, q, queryOpts, unsigned, capture, query, args...)
+				if err != nil {
+					return nil, err
+				}
+				cur = valuesToRowIter(names, valuesIter)
+				n = 0
+			}
+
+			row, err := cur(true)
+			if err != nil {
+				return nil, err
+			}
+			if row != nil {
+				n++
+				after = capture.values
+				return row, nil
+			}
+
+			// Page end.
+			if _, err := cur(false); err != nil {
+				return nil, err
+			}
+			cur = nil
+			done = n < pageSize
+		}
+	}
+
+	lastKey = func() []interface{} {
+		return after
+	}
+
+	return iter, lastKey, nil
+}
+
+func (tq *TableQuery) queryOptions() *QueryOptions {
+	return &QueryOptions{
+		Table:        tq.Table,
+		Canonical:    tq.Canonical,
+		TimeAsString: tq.TimeAsString,
+		ValueMapper:  tq.ValueMapper,
+		ColumnRules:  tq.ColumnRules,
+	}
+}
+
+func (tq *TableQuery) needPK() bool {
+	return tq.OrderByPK || tq.After != nil || tq.Until != nil
+}
+
+// QuoteIdent backtick-escapes an identifier.
+func QuoteIdent(ident string) string {
+	return "`" + strings.Replace(ident, "`", "``", -1) + "`"
+}
+
+// QuoteIdents backtick-escapes identifiers and joins them with ", ".
+func QuoteIdents(idents []string) string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = QuoteIdent(ident)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func containsString(strs []string, s string) bool {
+	return indexOfString(strs, s) >= 0
+}
+
+func indexOfString(strs []string, s string) int {
+	for i, str := range strs {
+		if str == s {
+			return i
+		}
+	}
+	return -1
+}
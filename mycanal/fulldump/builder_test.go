@@ -0,0 +1,71 @@
+package fulldump
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableQuerySQL(t *testing.T) {
+	assert := assert.New(t)
+
+	table := TableRef{Schema: "db", Table: "order`s"}
+
+	for i, testCase := range []struct {
+		Query       *TableQuery
+		PK          []string
+		ExpectQuery string
+		ExpectArgs  []interface{}
+		ExpectErr   bool
+	}{
+		{
+			Query:       &TableQuery{Table: table},
+			ExpectQuery: "SELECT * FROM `db`.`order``s`",
+		},
+		{
+			Query: &TableQuery{
+				Table:   table,
+				Columns: []string{"id", "select"},
+				Where:   "tenant_id = ? OR tenant_id = ?",
+				Args:    []interface{}{1, 2},
+			},
+			ExpectQuery: "SELECT `id`, `select` FROM `db`.`order``s` WHERE (tenant_id = ? OR tenant_id = ?)",
+			ExpectArgs:  []interface{}{1, 2},
+		},
+		{
+			Query: &TableQuery{
+				Table:     table,
+				Where:     "tenant_id = ?",
+				Args:      []interface{}{1},
+				OrderByPK: true,
+				After:     []interface{}{3, "x"},
+				Until:     []interface{}{9, "y"},
+				Limit:     10,
+				Offset:    5,
+			},
+			PK:          []string{"a", "b"},
+			ExpectQuery: "SELECT * FROM `db`.`order``s` WHERE (tenant_id = ?) AND (`a`, `b`) > (?, ?) AND (`a`, `b`) <= (?, ?) ORDER BY `a`, `b` LIMIT 10 OFFSET 5",
+			ExpectArgs:  []interface{}{1, 3, "x", 9, "y"},
+		},
+		// No primary key.
+		{
+			Query:     &TableQuery{Table: table, OrderByPK: true},
+			ExpectErr: true,
+		},
+		// Mismatch key length.
+		{
+			Query:     &TableQuery{Table: table, After: []interface{}{1}},
+			PK:        []string{"a", "b"},
+			ExpectErr: true,
+		},
+	} {
+		query, args, err := testCase.Query.SQL(testCase.PK)
+		if testCase.ExpectErr {
+			assert.Error(err, "test case %d", i)
+			continue
+		}
+		assert.NoError(err, "test case %d", i)
+		assert.Equal(testCase.ExpectQuery, query, "test case %d", i)
+		assert.Equal(testCase.ExpectArgs, args, "test case %d", i)
+	}
+}
@@ -8,7 +8,17 @@ import (
 	. "github.com/huangjunwen/golibs/mycanal"
 )
 
-// FullDump is similar to  mysqldump --single-transaction, see mycanal's doc for prerequisites.
+// FullDump is equivalent to FullDumpOpts() with opts == nil.
+func FullDump(
+	ctx context.Context,
+	cfg *Config,
+	handler Handler,
+) (gtidSet string, err error) {
+	return FullDumpOpts(ctx, cfg, nil, handler)
+}
+
+// FullDumpOpts is similar to  mysqldump --single-transaction, see mycanal's doc for prerequisites.
+// See LockMode for how the consistent snapshot is taken.
 //
 // NOTE: Since `BeginTx` can not run 'START TRANSACTION WITH CONSISTENT SNAPSHOT', use `*sql.Conn` instead of `*sql.Tx`.
 // More discussions:
@@ -16,72 +26,41 @@ import (
 //
 // Other refs:
 //   - https://issues.redhat.com/browse/DBZ-210
-func FullDump(
+//
+// Since handler reads by itself, progress is not tracked automatically: set opts.Progress and use its
+// AddTables (for estimations) and Track in handler, then it's reported to opts.OnProgress as ParallelDump.
+// For the same reason opts.ColumnRules can't be applied and is rejected: pass them in QueryOptions
+// (e.g. of DumpSchemas) in handler instead.
+func FullDumpOpts(
 	ctx context.Context,
 	cfg *Config,
+	opts *Options,
 	handler Handler,
 ) (gtidSet string, err error) {
 
-	// Some commands does not need cancel.
-	bgCtx := context.Background()
-
-	db, err := cfg.Client()
-	if err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump open client error")
+	if opts.columnRules() != nil {
+		return "", errors.New("fulldump.FullDumpOpts: ColumnRules can't be applied, pass them in QueryOptions in handler")
 	}
-	defer db.Close()
 
-	conn, err := db.Conn(ctx)
+	s, err := openSnapshot(ctx, cfg, opts, 1)
 	if err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump open conn error")
+		return "", errors.WithMessage(err, "fulldump.FullDumpOpts")
 	}
-	defer conn.Close()
+	defer s.Close()
 
-	// 0. Set isolation level to repeatable read (the default).
-	if _, err = conn.ExecContext(bgCtx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump set isolation level error")
-	}
-
-	// 1. Lock tables: to get current GTID set and start trx.
-	// NOTE: the lock will be released if connection closed.
-	_, err = conn.ExecContext(bgCtx, "FLUSH TABLES WITH READ LOCK")
-	if err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump ftwrl error")
-	}
-	defer func() {
-		// XXX: to ensure unlock is run
-		conn.ExecContext(bgCtx, "UNLOCK TABLES")
-	}()
-
-	// 2. Start trx with consistent snapshot.
-	if _, err = conn.ExecContext(bgCtx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump start transaction error")
-	}
-	defer func() {
-		// fulldump should not modify data
-		conn.ExecContext(bgCtx, "ROLLBACK")
-	}()
-
-	// 3. Get GTID_EXECUTED
-	err = conn.QueryRowContext(bgCtx, "SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet)
-	if err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump get gtid error")
-	}
-	if gtidSet == "" {
-		return "", errors.Errorf("No GTID_EXECUTED, pls make sure you have turn on binlog and gtid mode")
-	}
-
-	// 4. Unlock tables.
-	_, err = conn.ExecContext(bgCtx, "UNLOCK TABLES")
-	if err != nil {
-		return "", errors.WithMessage(err, "fulldump.FullDump unlock tables error")
+	// Progress is tracked by handler through opts.Progress.
+	if progress := opts.trackedProgress(); progress != nil {
+		stopReport := startReport(ctx, opts, progress)
+		defer func() {
+			stopReport(err == nil)
+		}()
 	}
 
-	// 5. User function
-	err = handler(ctx, conn)
+	// User function
+	err = handler(ctx, s.conns[0])
 	if err != nil {
 		return "", err
 	}
 
-	return
+	return s.gtidSet, nil
 }
@@ -0,0 +1,47 @@
+package fulldump
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+func TestFullDumpOptsColumnRules(t *testing.T) {
+	assert := assert.New(t)
+
+	called := false
+	_, err := FullDumpOpts(context.Background(), &Config{}, &Options{
+		ColumnRules: &ColumnRules{Columns: map[string]*ColumnRule{"db.t.c": {Action: DropColumn}}},
+	}, func(ctx context.Context, q sqlh.Queryer) error {
+		called = true
+		return nil
+	})
+	assert.Error(err)
+	assert.False(called)
+}
+
+func TestOptionsQueryOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	mapper := &ValueMapper{DecimalAsDecimal: true}
+	assert.Equal(&QueryOptions{}, (*Options)(nil).QueryOptions())
+	assert.Equal(&QueryOptions{
+		Canonical:    true,
+		TimeAsString: true,
+		ValueMapper:  mapper,
+	}, (&Options{Canonical: true, TimeAsString: true, ValueMapper: mapper}).QueryOptions())
+}
+
+func TestOptionsLockMode(t *testing.T) {
+	assert := assert.New(t)
+
+	// FTWRL is the zero value.
+	assert.Equal(LockFTWRL, (*Options)(nil).lockMode())
+	assert.Equal(LockFTWRL, (&Options{}).lockMode())
+	assert.Equal(LockAuto, (&Options{Lock: LockAuto}).lockMode())
+	assert.Equal("ftwrl", LockMode(0).String())
+}
@@ -0,0 +1,171 @@
+package fulldump
+
+import (
+	"time"
+
+	"github.com/huangjunwen/golibs/logr"
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+var (
+	// DefaultParallelism is the default value of Options.Parallelism.
+	DefaultParallelism = 4
+
+	// DefaultChunkSize is the default value of Options.ChunkSize.
+	DefaultChunkSize = 100000
+
+	// DefaultProgressInterval is the default value of Options.ProgressInterval.
+	DefaultProgressInterval = 10 * time.Second
+
+	// DefaultLogger is the default value of Options.Logger.
+	DefaultLogger = logr.Nop
+)
+
+// Options is options used in FullDumpOpts/ParallelDump.
+type Options struct {
+	// Lock is the locking strategy to get a consistent snapshot. Default LockFTWRL.
+	Lock LockMode
+
+	// Parallelism is used in ParallelDump only, it is the number of connections (sharing the same consistent snapshot)
+	// to dump chunks concurrently.
+	//
+	// Use DefaultParallelism if not set.
+	Parallelism int
+
+	// ChunkSize is used in ParallelDump only, it is the max number of rows in a chunk.
+	//
+	// Use DefaultChunkSize if not set.
+	ChunkSize int
+
+	// Canonical, TimeAsString and ValueMapper are used in ParallelDump, see QueryOptions. In FullDumpOpts
+	// rows are read by handler, use QueryOptions() there (e.g. for DumpSchemas).
+	Canonical    bool
+	TimeAsString bool
+	ValueMapper  *ValueMapper
+
+	// ColumnRules is used in ParallelDump, see QueryOptions.ColumnRules. FullDumpOpts returns an error
+	// if it's set since rows are read by handler.
+	ColumnRules *ColumnRules
+
+	// Progress, if not nil, is where dump progress is tracked, so that it can be queried from other
+	// go routines. ParallelDump tracks progress automatically (with a new tracker if it's nil but
+	// OnProgress is set). In FullDumpOpts, handler should track progress by Progress.Track.
+	Progress *ProgressTracker
+
+	// OnProgress, if not nil, is called with current progress every ProgressInterval and once more
+	// when the dump finishes successfully. It's not called concurrently.
+	OnProgress func(*Progress)
+
+	// ProgressInterval is the interval to call OnProgress.
+	//
+	// Use DefaultProgressInterval if not set.
+	ProgressInterval time.Duration
+
+	// Throttle, if not nil, limits the reading speed in ParallelDump. It's not applied in FullDumpOpts
+	// since the handler reads by itself, use Throttle.Wrap there.
+	Throttle *Throttle
+
+	// Logger for logging.
+	//
+	// Use DefaultLogger if not set.
+	Logger logr.Logger
+}
+
+// QueryOptions returns QueryOptions with Canonical, TimeAsString and ValueMapper of opts, which can
+// be used in the handler of FullDumpOpts to get the same representations as ParallelDump and incrdump.
+func (opts *Options) QueryOptions() *QueryOptions {
+	return &QueryOptions{
+		Canonical:    opts.canonical(),
+		TimeAsString: opts.timeAsString(),
+		ValueMapper:  opts.valueMapper(),
+	}
+}
+
+func (opts *Options) lockMode() LockMode {
+	if opts != nil {
+		return opts.Lock
+	}
+	return LockFTWRL
+}
+
+func (opts *Options) parallelism() int {
+	if opts != nil && opts.Parallelism > 0 {
+		return opts.Parallelism
+	}
+	return DefaultParallelism
+}
+
+func (opts *Options) chunkSize() int {
+	if opts != nil && opts.ChunkSize > 0 {
+		return opts.ChunkSize
+	}
+	return DefaultChunkSize
+}
+
+func (opts *Options) canonical() bool {
+	return opts != nil && opts.Canonical
+}
+
+func (opts *Options) timeAsString() bool {
+	return opts != nil && opts.TimeAsString
+}
+
+func (opts *Options) valueMapper() *ValueMapper {
+	if opts == nil {
+		return nil
+	}
+	return opts.ValueMapper
+}
+
+func (opts *Options) columnRules() *ColumnRules {
+	if opts == nil {
+		return nil
+	}
+	return opts.ColumnRules
+}
+
+func (opts *Options) progress() *ProgressTracker {
+	if opts == nil {
+		return nil
+	}
+	if opts.Progress == nil && opts.OnProgress != nil {
+		return NewProgressTracker()
+	}
+	return opts.Progress
+}
+
+// trackedProgress returns opts.Progress, without creating one.
+func (opts *Options) trackedProgress() *ProgressTracker {
+	if opts == nil {
+		return nil
+	}
+	return opts.Progress
+}
+
+func (opts *Options) onProgress() func(*Progress) {
+	if opts == nil {
+		return nil
+	}
+	return opts.OnProgress
+}
+
+func (opts *Options) progressInterval() time.Duration {
+	if opts != nil && opts.ProgressInterval > 0 {
+		return opts.ProgressInterval
+	}
+	return DefaultProgressInterval
+}
+
+func (opts *Options) throttle() *Throttle {
+	if opts == nil {
+		return nil
+	}
+	return opts.Throttle
+}
+
+func (opts *Options) logger() logr.Logger {
+	if opts != nil && opts.Logger != nil {
+		return opts.Logger
+	}
+	return DefaultLogger
+}
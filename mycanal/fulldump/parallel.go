@@ -0,0 +1,321 @@
+package fulldump
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+// Chunk is a primary key range of a table.
+type Chunk struct {
+	TableRef
+
+	// Seq is the sequence number of the chunk in the table, starts from 0.
+	Seq int
+
+	// Last is true if this is the last chunk of the table.
+	Last bool
+
+	// PrimaryKey is the primary key column names of the table.
+	// Tables without primary key are dumped in a single chunk.
+	PrimaryKey []string
+
+	// LowerBound is the (exclusive) lower bound of primary key values, nil if unbounded.
+	LowerBound []interface{}
+
+	// UpperBound is the (inclusive) upper bound of primary key values, nil if unbounded.
+	UpperBound []interface{}
+
+	// unsigned is the unsigned integer columns of the table, shared by chunks of the table.
+	unsigned map[string]bool
+}
+
+// ChunkHandler is used to dump a chunk. The RowIter will be closed after the handler returns.
+//
+// NOTE: ChunkHandler is called concurrently from multiple go routines.
+type ChunkHandler func(ctx context.Context, chunk *Chunk, iter RowIter) error
+
+// ParallelDump is similar to FullDumpOpts but dumps tables concurrently.
+//
+// It opens opts.Parallelism connections with the same consistent snapshot, splits tables
+// into primary key ranges (at most opts.ChunkSize rows each) and dumps the chunks through
+// these connections. The first error returned by handler stops the whole dump.
+func ParallelDump(
+	ctx context.Context,
+	cfg *Config,
+	opts *Options,
+	tables []TableRef,
+	handler ChunkHandler,
+) (gtidSet string, err error) {
+
+	s, err := openSnapshot(ctx, cfg, opts, opts.parallelism())
+	if err != nil {
+		return "", errors.WithMessage(err, "fulldump.ParallelDump")
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// Track progress if needed.
+	progress := opts.progress()
+	if progress != nil {
+		if err := progress.AddTables(ctx, s.conns[0], tables); err != nil {
+			return "", errors.WithMessage(err, "fulldump.ParallelDump")
+		}
+		stopReport := startReport(ctx, opts, progress)
+		defer func() {
+			stopReport(err == nil)
+		}()
+	}
+
+	// The first connection plans chunks.
+	planConn := s.conns[0]
+	emit := func(chunk *Chunk) error {
+		if progress != nil {
+			progress.planChunk(chunk)
+		}
+		return dumpChunk(ctx, planConn, chunk, opts, progress, handler)
+	}
+
+	// Other connections (if any) dump chunks, and the first connection joins them after planning.
+	chunkCh := make(chan *Chunk)
+	worker := func(conn *sql.Conn) {
+		for chunk := range chunkCh {
+			if err := dumpChunk(ctx, conn, chunk, opts, progress, handler); err != nil {
+				setErr(err)
+				return
+			}
+		}
+	}
+
+	if len(s.conns) > 1 {
+		emit = func(chunk *Chunk) error {
+			if progress != nil {
+				progress.planChunk(chunk)
+			}
+			select {
+			case chunkCh <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for _, conn := range s.conns[1:] {
+			wg.Add(1)
+			go func(conn *sql.Conn) {
+				defer wg.Done()
+				worker(conn)
+			}(conn)
+		}
+	}
+
+	err = func() error {
+		defer close(chunkCh)
+		for _, table := range tables {
+			if err := planChunks(ctx, planConn, table, opts.chunkSize(), emit); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		setErr(err)
+	} else if len(s.conns) > 1 {
+		worker(planConn)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return s.gtidSet, nil
+}
+
+func dumpChunk(
+	ctx context.Context,
+	q sqlh.Queryer,
+	chunk *Chunk,
+	opts *Options,
+	progress *ProgressTracker,
+	handler ChunkHandler,
+) error {
+	tq := chunk.tableQuery()
+	tq.Canonical = opts.canonical()
+	tq.TimeAsString = opts.timeAsString()
+	tq.ValueMapper = opts.valueMapper()
+	tq.ColumnRules = opts.columnRules()
+
+	// Table metadata is queried once per table in planChunks, not per chunk.
+	query, args, err := tq.SQL(chunk.PrimaryKey)
+	if err != nil {
+		return err
+	}
+	names, valuesIter, err := queryValues(ctx, q, tq.queryOptions(), chunk.unsigned, nil, query, args...)
+	if err != nil {
+		return err
+	}
+	iter := valuesToRowIter(names, valuesIter)
+	defer iter(false)
+	if progress != nil {
+		iter = progress.trackChunk(chunk, iter)
+	}
+	if throttle := opts.throttle(); throttle != nil {
+		iter = throttle.Wrap(ctx, iter)
+	}
+	return handler(ctx, chunk, iter)
+}
+
+func (chunk *Chunk) tableQuery() *TableQuery {
+	return &TableQuery{
+		Table: chunk.TableRef,
+		After: chunk.LowerBound,
+		Until: chunk.UpperBound,
+	}
+}
+
+// planChunks splits a table into chunks by walking through its primary key.
+func planChunks(ctx context.Context, q sqlh.Queryer, table TableRef, chunkSize int, emit func(*Chunk) error) error {
+
+	pk, err := PrimaryKey(ctx, q, table)
+	if err != nil {
+		return err
+	}
+	unsigned, err := (&QueryOptions{Table: table}).unsignedColumns(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	// No primary key: the whole table.
+	if len(pk) == 0 {
+		return emit(&Chunk{
+			TableRef: table,
+			Last:     true,
+			unsigned: unsigned,
+		})
+	}
+
+	var lowerBound []interface{}
+	for seq := 0; ; seq++ {
+		chunk := &Chunk{
+			TableRef:   table,
+			Seq:        seq,
+			PrimaryKey: pk,
+			LowerBound: lowerBound,
+			unsigned:   unsigned,
+		}
+
+		// Find the upper bound: the chunkSize-th primary key after lower bound.
+		query, args, err := (&TableQuery{
+			Table:     table,
+			Columns:   pk,
+			After:     lowerBound,
+			OrderByPK: true,
+			Limit:     1,
+			Offset:    chunkSize - 1,
+		}).SQL(pk)
+		if err != nil {
+			return err
+		}
+
+		upperBound, err := queryKey(ctx, q, query, args...)
+		if err != nil {
+			return errors.WithMessagef(err, "fulldump plan chunks for %s.%s error", table.Schema, table.Table)
+		}
+
+		chunk.UpperBound = upperBound
+		chunk.Last = upperBound == nil
+		if err := emit(chunk); err != nil {
+			return err
+		}
+		if chunk.Last {
+			return nil
+		}
+		lowerBound = upperBound
+	}
+}
+
+// PrimaryKey returns primary key column names (in order) of a table, empty if the table has no primary key.
+func PrimaryKey(ctx context.Context, q sqlh.Queryer, table TableRef) ([]string, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		"SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+			"WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND CONSTRAINT_NAME='PRIMARY' "+
+			"ORDER BY ORDINAL_POSITION",
+		table.Schema,
+		table.Table,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "fulldump query primary key error")
+	}
+	defer rows.Close()
+
+	ret := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.WithMessage(err, "fulldump scan primary key error")
+		}
+		ret = append(ret, name)
+	}
+	return ret, errors.WithMessage(rows.Err(), "fulldump query primary key error")
+}
+
+// queryKey queries a single row of key values, returns nil if no row.
+func queryKey(ctx context.Context, q sqlh.Queryer, query string, args ...interface{}) ([]interface{}, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	ret := make([]interface{}, len(colTypes))
+	ptrs := make([]interface{}, len(colTypes))
+	for i := range ret {
+		ptrs[i] = &ret[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	// NOTE: Non binary strings must be passed back as string (instead of []byte, which
+	// is interpolated as binary string) so that comparison uses the column's collation.
+	for i, colType := range colTypes {
+		if b, ok := ret[i].([]byte); ok && !isBinaryType(colType.DatabaseTypeName()) {
+			ret[i] = string(b)
+		}
+	}
+	return ret, nil
+}
+
+func isBinaryType(typeName string) bool {
+	return strings.HasSuffix(typeName, "BINARY") || strings.HasSuffix(typeName, "BLOB")
+}
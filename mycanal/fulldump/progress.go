@@ -0,0 +1,301 @@
+package fulldump
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+// Progress is a snapshot of dump progress.
+type Progress struct {
+	// Tables are progresses of tables, in the order of being added.
+	Tables []TableProgress
+
+	// Rows is the number of rows dumped.
+	Rows int64
+
+	// EstimatedRows is the estimated number of rows of all tables.
+	EstimatedRows int64
+
+	// Bytes is the approximate size of values dumped.
+	Bytes int64
+
+	// EstimatedBytes is the estimated data size (information_schema.TABLES.DATA_LENGTH) of all tables.
+	// Since it's the on-disk size, it's not comparable with Bytes.
+	EstimatedBytes int64
+
+	// Fraction is the estimated completed fraction (0~1), tables are weighted by their data size.
+	Fraction float64
+
+	// Elapsed is the time elapsed since the tracker created.
+	Elapsed time.Duration
+
+	// ETA is the estimated remaining time, -1 if unknown.
+	ETA time.Duration
+}
+
+// TableProgress is the progress of a table.
+type TableProgress struct {
+	TableRef
+
+	// Rows is the number of rows dumped.
+	Rows int64
+
+	// EstimatedRows is from information_schema.TABLES.TABLE_ROWS, which is an estimation for InnoDB.
+	EstimatedRows int64
+
+	// Bytes is the approximate size of values dumped.
+	Bytes int64
+
+	// EstimatedBytes is from information_schema.TABLES.DATA_LENGTH.
+	EstimatedBytes int64
+
+	// Done is true if the table is completely dumped.
+	Done bool
+}
+
+// ProgressTracker tracks dump progress. It's safe for concurrent use, so Progress can be
+// queried from other go routines.
+type ProgressTracker struct {
+	mu     sync.Mutex
+	start  time.Time
+	tables []*tableTracker
+	index  map[TableRef]*tableTracker
+}
+
+type tableTracker struct {
+	TableProgress
+
+	// For ParallelDump.
+	planned  bool // all chunks planned
+	chunks   int  // chunks planned
+	finished int  // chunks finished
+}
+
+// NewProgressTracker creates a ProgressTracker.
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{
+		start: time.Now(),
+		index: map[TableRef]*tableTracker{},
+	}
+}
+
+// AddTables adds tables to track and loads their estimated sizes from information_schema.TABLES.
+// Tables already added are ignored.
+//
+// NOTE: information_schema.TABLES statistics are cached by MySQL (see information_schema_stats_expiry),
+// run 'ANALYZE TABLE' to refresh them if needed.
+func (t *ProgressTracker) AddTables(ctx context.Context, q sqlh.Queryer, tables []TableRef) error {
+	for _, table := range tables {
+		t.mu.Lock()
+		_, ok := t.index[table]
+		t.mu.Unlock()
+		if ok {
+			continue
+		}
+
+		tt := &tableTracker{}
+		tt.TableRef = table
+		err := q.QueryRowContext(
+			ctx,
+			"SELECT IFNULL(TABLE_ROWS, 0), IFNULL(DATA_LENGTH, 0) FROM information_schema.TABLES "+
+				"WHERE TABLE_SCHEMA=? AND TABLE_NAME=?",
+			table.Schema,
+			table.Table,
+		).Scan(&tt.EstimatedRows, &tt.EstimatedBytes)
+		if err != nil {
+			return errors.WithMessagef(err, "fulldump.ProgressTracker query estimation of %s error", table.Quoted())
+		}
+
+		t.mu.Lock()
+		if _, ok := t.index[table]; !ok {
+			t.index[table] = tt
+			t.tables = append(t.tables, tt)
+		}
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// Track wraps a RowIter iterating all rows of a table to count rows and bytes. The table is
+// marked as done when the iterator is exhausted. The table is added (without estimation) if not yet.
+func (t *ProgressTracker) Track(table TableRef, iter RowIter) RowIter {
+	tt := t.table(table)
+	return func(next bool) (map[string]interface{}, error) {
+		row, err := iter(next)
+		if next && err == nil {
+			t.mu.Lock()
+			if row != nil {
+				tt.Rows++
+				tt.Bytes += rowSize(row)
+			} else {
+				tt.Done = true
+			}
+			t.mu.Unlock()
+		}
+		return row, err
+	}
+}
+
+// Progress returns a snapshot of current progress.
+func (t *ProgressTracker) Progress() *Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ret := &Progress{
+		Tables:  make([]TableProgress, 0, len(t.tables)),
+		Elapsed: time.Since(t.start),
+		ETA:     -1,
+	}
+
+	var totalWeight, doneWeight float64
+	for _, tt := range t.tables {
+		ret.Tables = append(ret.Tables, tt.TableProgress)
+		ret.Rows += tt.Rows
+		ret.EstimatedRows += tt.EstimatedRows
+		ret.Bytes += tt.Bytes
+		ret.EstimatedBytes += tt.EstimatedBytes
+
+		// Weighted by data size (at least 1 for empty tables).
+		weight := float64(tt.EstimatedBytes)
+		if weight < 1 {
+			weight = 1
+		}
+		totalWeight += weight
+		switch {
+		case tt.Done:
+			doneWeight += weight
+		case tt.Rows >= tt.EstimatedRows:
+			// Estimation is too small, assume almost done.
+			doneWeight += weight * 0.99
+		default:
+			doneWeight += weight * float64(tt.Rows) / float64(tt.EstimatedRows)
+		}
+	}
+
+	if totalWeight > 0 {
+		ret.Fraction = doneWeight / totalWeight
+	}
+	switch {
+	case ret.Fraction >= 1:
+		ret.ETA = 0
+	case ret.Fraction > 0:
+		ret.ETA = time.Duration(float64(ret.Elapsed) * (1 - ret.Fraction) / ret.Fraction)
+	}
+	return ret
+}
+
+// Report calls fn with current progress every interval until ctx done.
+func (t *ProgressTracker) Report(ctx context.Context, interval time.Duration, fn func(*Progress)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(t.Progress())
+		}
+	}
+}
+
+// startReport starts reporting progress to opts.OnProgress (if set) in background. The returned function
+// stops reporting and waits for the background go routine to exit, then reports the final progress if
+// final is true. So OnProgress is never called concurrently.
+func startReport(ctx context.Context, opts *Options, progress *ProgressTracker) (stop func(final bool)) {
+	onProgress := opts.onProgress()
+	if onProgress == nil || progress == nil {
+		return func(bool) {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		progress.Report(ctx, opts.progressInterval(), onProgress)
+	}()
+
+	return func(final bool) {
+		cancel()
+		<-done
+		if final {
+			onProgress(progress.Progress())
+		}
+	}
+}
+
+func (t *ProgressTracker) table(table TableRef) *tableTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tt, ok := t.index[table]
+	if !ok {
+		tt = &tableTracker{}
+		tt.TableRef = table
+		t.index[table] = tt
+		t.tables = append(t.tables, tt)
+	}
+	return tt
+}
+
+// planChunk is called when a chunk is planned in ParallelDump.
+func (t *ProgressTracker) planChunk(chunk *Chunk) {
+	tt := t.table(chunk.TableRef)
+	t.mu.Lock()
+	tt.chunks++
+	if chunk.Last {
+		tt.planned = true
+	}
+	t.mu.Unlock()
+}
+
+// trackChunk is similar to Track but for a chunk in ParallelDump.
+func (t *ProgressTracker) trackChunk(chunk *Chunk, iter RowIter) RowIter {
+	tt := t.table(chunk.TableRef)
+	finished := false
+	return func(next bool) (map[string]interface{}, error) {
+		row, err := iter(next)
+		if next && err == nil {
+			t.mu.Lock()
+			if row != nil {
+				tt.Rows++
+				tt.Bytes += rowSize(row)
+			} else if !finished {
+				finished = true
+				tt.finished++
+				tt.Done = tt.planned && tt.finished == tt.chunks
+			}
+			t.mu.Unlock()
+		}
+		return row, err
+	}
+}
+
+// rowSize returns the approximate size of row values.
+func rowSize(row map[string]interface{}) int64 {
+	ret := int64(0)
+	for _, v := range row {
+		switch val := v.(type) {
+		case nil:
+		case string:
+			ret += int64(len(val))
+		case []byte:
+			ret += int64(len(val))
+		case Geometry:
+			ret += int64(4 + len(val.WKB))
+		case int8, uint8:
+			ret++
+		case int16, uint16:
+			ret += 2
+		case int32, uint32, float32:
+			ret += 4
+		default:
+			ret += 8
+		}
+	}
+	return ret
+}
@@ -0,0 +1,105 @@
+package fulldump
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	rows := func(n int) RowIter {
+		return func(next bool) (map[string]interface{}, error) {
+			if !next || n == 0 {
+				return nil, nil
+			}
+			n--
+			return map[string]interface{}{"a": int32(1), "b": "xyz", "c": nil}, nil
+		}
+	}
+	drain := func(iter RowIter) {
+		for {
+			row, _ := iter(true)
+			if row == nil {
+				return
+			}
+		}
+	}
+
+	tracker := NewProgressTracker()
+	tracker.start = time.Now().Add(-time.Minute)
+	a := TableRef{Schema: "s", Table: "a"}
+	b := TableRef{Schema: "s", Table: "b"}
+	tracker.table(a).EstimatedRows = 10
+	tracker.table(a).EstimatedBytes = 300
+	tracker.table(b).EstimatedRows = 10
+	tracker.table(b).EstimatedBytes = 100
+
+	// Nothing dumped.
+	p := tracker.Progress()
+	assert.Equal(int64(20), p.EstimatedRows)
+	assert.Equal(int64(400), p.EstimatedBytes)
+	assert.Equal(0.0, p.Fraction)
+	assert.Equal(time.Duration(-1), p.ETA)
+
+	// Table b done.
+	drain(tracker.Track(b, rows(10)))
+	p = tracker.Progress()
+	assert.Equal(int64(10), p.Rows)
+	assert.Equal(int64(10*7), p.Bytes)
+	assert.Equal(0.25, p.Fraction)
+	assert.True(p.Tables[1].Done)
+	assert.InDelta(float64(3*p.Elapsed), float64(p.ETA), float64(time.Second))
+
+	// Half of table a.
+	iter := tracker.Track(a, rows(5))
+	for i := 0; i < 5; i++ {
+		iter(true)
+	}
+	p = tracker.Progress()
+	assert.False(p.Tables[0].Done)
+	assert.Equal(0.25+0.75*0.5, p.Fraction)
+
+	// More rows than estimated.
+	drain(tracker.Track(a, rows(20)))
+	p = tracker.Progress()
+	assert.Equal(int64(35), p.Rows)
+	assert.Equal(1.0, p.Fraction)
+	assert.Equal(time.Duration(0), p.ETA)
+}
+
+func TestStartReport(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		calls   int32
+		running int32
+		overlap bool
+	)
+	opts := &Options{
+		ProgressInterval: time.Millisecond,
+		OnProgress: func(p *Progress) {
+			if atomic.AddInt32(&running, 1) != 1 {
+				overlap = true
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&calls, 1)
+			atomic.AddInt32(&running, -1)
+		},
+	}
+
+	stop := startReport(context.Background(), opts, NewProgressTracker())
+	time.Sleep(20 * time.Millisecond)
+	stop(true)
+
+	// No more calls after stop returns.
+	n := atomic.LoadInt32(&calls)
+	assert.True(n > 1)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(n, atomic.LoadInt32(&calls))
+	assert.False(overlap)
+}
@@ -2,10 +2,11 @@ package fulldump
 
 import (
 	"context"
-	"fmt"
+	"sync"
 
 	"github.com/pkg/errors"
 
+	. "github.com/huangjunwen/golibs/mycanal"
 	"github.com/huangjunwen/golibs/sqlh"
 )
 
@@ -13,12 +14,126 @@ import (
 // Caller should invoke RowIter(false) to close the iterator and release resource.
 type RowIter func(next bool) (map[string]interface{}, error)
 
-// Query and returns RowIter
+// ValuesIter is similar to RowIter but returns row data positionally (aligned with column names
+// returned by QueryValues). It returns nil if no more row.
+//
+// NOTE: The returned slice is reused between calls, caller must copy it if it's needed after next call.
+// Caller should invoke ValuesIter(false) to close the iterator and release resource.
+type ValuesIter func(next bool) ([]interface{}, error)
+
+// scanBuffer holds scan targets and output values of a result set.
+type scanBuffer struct {
+	targets []interface{}
+	values  []interface{}
+}
+
+var (
+	scanBufferPool = sync.Pool{
+		New: func() interface{} {
+			return &scanBuffer{}
+		},
+	}
+)
+
+// QueryOptions is options used in QueryOpts/QueryValuesOpts.
+type QueryOptions struct {
+	// Table is the table being queried, optional. If set, column metadata is read from
+	// information_schema to supplement result set metadata, e.g. signedness of integer columns
+	// for drivers not reporting it in ColumnType.
+	Table TableRef
+
+	// Canonical makes values identical to the ones returned by incrdump with canonical mode (except
+	// JSON doubles with integral value, see incrdump.Options.Canonical): JSON values are re-serialized
+	// by CanonicalJSON. DECIMAL (scaled to the declared scale) and BINARY (padded to the declared length)
+	// values returned by MySQL are already canonical.
+	Canonical bool
+
+	// TimeAsString returns TIME values as string (e.g. "-01:02:03.456") instead of time.Duration.
+	TimeAsString bool
+
+	// ValueMapper maps values to other representations, optional.
+	ValueMapper *ValueMapper
+
+	// ColumnRules are applied to rows (after ValueMapper), optional. It's not used if Table is not set.
+	// Dropped columns are not included in returned column names.
+	ColumnRules *ColumnRules
+}
+
+// mappedColumn is a column whose values are mapped by ValueMapper.
+type mappedColumn struct {
+	index  int
+	kind   ValueKind
+	mapper *ValueMapper
+}
+
+// Query is equivalent to QueryOpts() with opts == nil.
 func Query(ctx context.Context, q sqlh.Queryer, query string, args ...interface{}) (iter RowIter, err error) {
+	return QueryOpts(ctx, q, nil, query, args...)
+}
+
+// QueryOpts queries and returns RowIter.
+func QueryOpts(ctx context.Context, q sqlh.Queryer, opts *QueryOptions, query string, args ...interface{}) (iter RowIter, err error) {
+
+	names, valuesIter, err := QueryValuesOpts(ctx, q, opts, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return valuesToRowIter(names, valuesIter), nil
+}
+
+func valuesToRowIter(names []string, valuesIter ValuesIter) RowIter {
+	return func(next bool) (map[string]interface{}, error) {
+		values, err := valuesIter(next)
+		if values == nil {
+			return nil, err
+		}
+
+		m := make(map[string]interface{}, len(names))
+		for i, name := range names {
+			m[name] = values[i]
+		}
+
+		return m, nil
+	}
+}
+
+// QueryValues is equivalent to QueryValuesOpts() with opts == nil.
+func QueryValues(ctx context.Context, q sqlh.Queryer, query string, args ...interface{}) (names []string, iter ValuesIter, err error) {
+	return QueryValuesOpts(ctx, q, nil, query, args...)
+}
+
+// QueryValuesOpts queries and returns column names and ValuesIter. Scan buffers are allocated once
+// per query (and pooled across queries) and no map is allocated per row, so it's more efficient than
+// QueryOpts for large result sets. It's not allocation-free though: values are boxed into interface{}
+// per row (e.g. non-small integers, strings and times allocate). For rows of 4 columns in
+// BenchmarkQueryValues it's about 6 allocs/row vs 8 for BenchmarkQuery, not counting the driver's
+// (BenchmarkFakeRows).
+func QueryValuesOpts(ctx context.Context, q sqlh.Queryer, opts *QueryOptions, query string, args ...interface{}) (names []string, iter ValuesIter, err error) {
+
+	// NOTE: Must be done before the query since the connection is busy until rows closed.
+	unsigned, err := opts.unsignedColumns(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return queryValues(ctx, q, opts, unsigned, nil, query, args...)
+}
+
+// keyCapture captures key values of the last row read, see queryValues.
+type keyCapture struct {
+	// columns is the key column names.
+	columns []string
+
+	// values is the key values of the last row read. They are captured before conversions (e.g. TIME
+	// to time.Duration), ValueMapper and ColumnRules, so that they can be passed back as query args.
+	values []interface{}
+}
+
+func queryValues(ctx context.Context, q sqlh.Queryer, opts *QueryOptions, unsigned map[string]bool, capture *keyCapture, query string, args ...interface{}) (names []string, iter ValuesIter, err error) {
 
 	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, errors.WithMessage(err, "fulldump.Query error")
+		return nil, nil, errors.WithMessage(err, "fulldump.Query error")
 	}
 	defer func() {
 		if err != nil {
@@ -26,16 +141,91 @@ func Query(ctx context.Context, q sqlh.Queryer, query string, args ...interface{
 		}
 	}()
 
-	names, err := rows.Columns()
+	names, err = rows.Columns()
 	if err != nil {
-		return nil, errors.WithMessage(err, "fulldump.Query get Columns error")
+		return nil, nil, errors.WithMessage(err, "fulldump.Query get Columns error")
 	}
 
-	makeScanValues := makeScanValues(rows)
-	values := []interface{}(nil)
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "fulldump.Query get ColumnTypes error")
+	}
+	cols := make([]columnInfo, len(colTypes))
+	jsonCols := []int{}
+	timeCols := []int{}
+	geometryCols := []int{}
+	mappedCols := []mappedColumn{}
+	for i, colType := range colTypes {
+		cols[i] = newColumnInfo(colType, unsigned[colType.Name()])
+		switch {
+		case cols[i].typeName == "JSON" && opts.canonical():
+			jsonCols = append(jsonCols, i)
+		case cols[i].typeName == "TIME" && !opts.timeAsString():
+			timeCols = append(timeCols, i)
+		case cols[i].typeName == "GEOMETRY":
+			geometryCols = append(geometryCols, i)
+		}
+		if kind := valueKind(cols[i].typeName); kind != OtherValue && opts.valueMapper() != nil {
+			mappedCols = append(mappedCols, mappedColumn{
+				index:  i,
+				kind:   kind,
+				mapper: opts.columnValueMapper(colType.Name()),
+			})
+		}
+	}
+	// Key columns to capture.
+	keyCols := []int{}
+	if capture != nil {
+		for _, name := range capture.columns {
+			i := indexOfString(names, name)
+			if i < 0 {
+				return nil, nil, errors.Errorf("fulldump.Query: key column %q not found in result", name)
+			}
+			keyCols = append(keyCols, i)
+		}
+	}
 
-	return func(next bool) (map[string]interface{}, error) {
+	// Columns not dropped by column rules.
+	rules := opts.tableRules()
+	keptCols := []int{}
+	keptNames := []string{}
+	for i, name := range names {
+		if !rules.Drop(name) {
+			keptCols = append(keptCols, i)
+			keptNames = append(keptNames, name)
+		}
+	}
+	var keptValues []interface{}
+	if len(keptCols) != len(names) {
+		keptValues = make([]interface{}, len(keptCols))
+	}
+
+	makeScanValues, err := makeScanValues(cols)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "fulldump.Query")
+	}
+
+	buf := scanBufferPool.Get().(*scanBuffer)
+	buf.targets = makeScanValues(buf.targets)
+	buf.values = append(buf.values[:0], make([]interface{}, len(names))...)
+
+	release := func() {
+		if buf == nil {
+			return
+		}
+		for i := range buf.values {
+			buf.values[i] = nil
+		}
+		for i := range buf.targets {
+			buf.targets[i] = nil
+		}
+		scanBufferPool.Put(buf)
+		buf = nil
+	}
+
+	return keptNames, func(next bool) ([]interface{}, error) {
 		if !next {
+			release()
 			return nil, errors.WithMessage(rows.Close(), "fulldump.Query close rows error")
 		}
 
@@ -43,23 +233,141 @@ func Query(ctx context.Context, q sqlh.Queryer, query string, args ...interface{
 			return nil, errors.WithMessage(rows.Err(), "fulldump.Query rows error")
 		}
 
-		values = makeScanValues(values)
-		if err := rows.Scan(values...); err != nil {
+		if err := rows.Scan(buf.targets...); err != nil {
 			return nil, errors.WithMessage(err, "fulldump.Query scan error")
 		}
-		postProcessScanedValues(values)
+		postProcessScanedValues(buf.values, buf.targets)
 
-		m := make(map[string]interface{})
-		for i, name := range names {
-			m[name] = values[i]
+		if capture != nil {
+			// NOTE: Binary strings must be passed back as []byte, see queryKey.
+			values := make([]interface{}, len(keyCols))
+			for j, i := range keyCols {
+				values[j] = buf.values[i]
+				if v, ok := values[j].(string); ok && isBinaryType(cols[i].typeName) {
+					values[j] = []byte(v)
+				}
+			}
+			capture.values = values
 		}
 
-		return m, nil
+		for _, i := range jsonCols {
+			if v, ok := buf.values[i].(string); ok && v != "" {
+				canonicalValue, err := CanonicalJSON(v)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "fulldump.Query canonical json error for column %q", names[i])
+				}
+				buf.values[i] = canonicalValue
+			}
+		}
+
+		for _, i := range timeCols {
+			if v, ok := buf.values[i].(string); ok {
+				d, err := ParseTime(v)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "fulldump.Query parse time error for column %q", names[i])
+				}
+				buf.values[i] = d
+			}
+		}
+
+		for _, i := range geometryCols {
+			if v, ok := buf.values[i].(string); ok {
+				g, err := ParseGeometry([]byte(v))
+				if err != nil {
+					return nil, errors.WithMessagef(err, "fulldump.Query parse geometry error for column %q", names[i])
+				}
+				buf.values[i] = g
+			}
+		}
+
+		for _, col := range mappedCols {
+			v, err := col.mapper.Map(col.kind, buf.values[col.index])
+			if err != nil {
+				return nil, errors.WithMessagef(err, "fulldump.Query map value error for column %q", names[col.index])
+			}
+			buf.values[col.index] = v
+		}
+
+		if rules == nil {
+			return buf.values, nil
+		}
+		for _, i := range keptCols {
+			buf.values[i] = rules.Apply(names[i], buf.values[i])
+		}
+		if keptValues == nil {
+			return buf.values, nil
+		}
+		for j, i := range keptCols {
+			keptValues[j] = buf.values[i]
+		}
+		return keptValues, nil
 	}, nil
 }
 
-// FullTableQuery full dump a table.
+// FullTableQuery full dump a table. See TableQuery for projected/filtered/ordered queries.
 func FullTableQuery(ctx context.Context, q sqlh.Queryer, dbName, table string) (RowIter, error) {
-	query := fmt.Sprintf("SELECT * FROM %s.%s", dbName, table)
-	return Query(ctx, q, query)
+	return (&TableQuery{
+		Table: TableRef{Schema: dbName, Table: table},
+	}).Query(ctx, q)
+}
+
+func (opts *QueryOptions) canonical() bool {
+	return opts != nil && opts.Canonical
+}
+
+func (opts *QueryOptions) timeAsString() bool {
+	return opts != nil && opts.TimeAsString
+}
+
+func (opts *QueryOptions) valueMapper() *ValueMapper {
+	if opts == nil {
+		return nil
+	}
+	return opts.ValueMapper
+}
+
+func (opts *QueryOptions) tableRules() *TableRules {
+	if opts == nil || opts.Table.Table == "" {
+		return nil
+	}
+	return opts.ColumnRules.ForTable(opts.Table.Schema, opts.Table.Table)
+}
+
+// columnValueMapper returns the ValueMapper for a result column, per-column overrides are used
+// only if the source table is known.
+func (opts *QueryOptions) columnValueMapper(name string) *ValueMapper {
+	mapper := opts.valueMapper()
+	if opts.Table.Table == "" {
+		return mapper
+	}
+	return mapper.ForColumn(opts.Table.Schema, opts.Table.Table, name)
+}
+
+// unsignedColumns returns unsigned integer columns of opts.Table, or nil if not set.
+func (opts *QueryOptions) unsignedColumns(ctx context.Context, q sqlh.Queryer) (map[string]bool, error) {
+	if opts == nil || opts.Table.Table == "" {
+		return nil, nil
+	}
+
+	rows, err := q.QueryContext(
+		ctx,
+		"SELECT COLUMN_NAME FROM information_schema.COLUMNS "+
+			"WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND COLUMN_TYPE LIKE '%unsigned%'",
+		opts.Table.Schema,
+		opts.Table.Table,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "fulldump query column metadata error")
+	}
+	defer rows.Close()
+
+	ret := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.WithMessage(err, "fulldump scan column metadata error")
+		}
+		ret[name] = true
+	}
+	return ret, errors.WithMessage(rows.Err(), "fulldump query column metadata error")
 }
@@ -0,0 +1,257 @@
+package fulldump
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDriver returns n rows for query "n" with a fixed set of columns.
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeRows struct {
+	n int
+	i int
+}
+
+type fakeColumn struct {
+	name     string
+	typeName string
+	nullable bool
+	scanType reflect.Type
+}
+
+var (
+	fakeColumns = []fakeColumn{
+		{"id", "BIGINT", false, reflect.TypeOf(int64(0))},
+		{"name", "VARCHAR", true, reflect.TypeOf(sql.RawBytes{})},
+		{"amount", "DECIMAL", false, reflect.TypeOf(sql.RawBytes{})},
+		{"created_at", "DATETIME", true, reflect.TypeOf(sql.NullTime{})},
+	}
+
+	fakeTime = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Shared by rows so that the driver itself allocates as little as possible per row.
+	fakeName      driver.Value = []byte("name")
+	fakeAmount    driver.Value = []byte("1.23")
+	fakeTimeValue driver.Value = fakeTime
+)
+
+func init() {
+	sql.Register("fulldump-fake", fakeDriver{})
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	n, err := strconv.Atoi(query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{n: n}, nil
+}
+
+func (rows *fakeRows) Columns() []string {
+	ret := make([]string, len(fakeColumns))
+	for i, col := range fakeColumns {
+		ret[i] = col.name
+	}
+	return ret
+}
+
+func (rows *fakeRows) Close() error { return nil }
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.i >= rows.n {
+		return io.EOF
+	}
+	dest[0] = int64(rows.i)
+	dest[1] = fakeName
+	dest[2] = fakeAmount
+	dest[3] = fakeTimeValue
+	rows.i++
+	return nil
+}
+
+func (rows *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return fakeColumns[i].typeName }
+
+func (rows *fakeRows) ColumnTypeNullable(i int) (bool, bool) { return fakeColumns[i].nullable, true }
+
+func (rows *fakeRows) ColumnTypeScanType(i int) reflect.Type { return fakeColumns[i].scanType }
+
+func openFakeDB() *sql.DB {
+	db, err := sql.Open("fulldump-fake", "")
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func TestQueryValues(t *testing.T) {
+	assert := assert.New(t)
+
+	db := openFakeDB()
+	defer db.Close()
+
+	names, iter, err := QueryValues(context.Background(), db, "3")
+	assert.NoError(err)
+	assert.Equal([]string{"id", "name", "amount", "created_at"}, names)
+
+	var first []interface{}
+	for i := 0; ; i++ {
+		values, err := iter(true)
+		assert.NoError(err)
+		if values == nil {
+			assert.Equal(3, i)
+			break
+		}
+		assert.Equal([]interface{}{int64(i), "name", "1.23", fakeTime}, values)
+
+		// The returned slice is reused between rows.
+		if first == nil {
+			first = values
+		}
+		assert.Equal(&first[0], &values[0])
+	}
+	assert.NoError(closeValuesIter(iter))
+
+	// Released buffer holds no values.
+	buf := scanBufferPool.Get().(*scanBuffer)
+	for _, v := range buf.values {
+		assert.Nil(v)
+	}
+	for _, v := range buf.targets {
+		assert.Nil(v)
+	}
+	scanBufferPool.Put(buf)
+}
+
+func TestQueryValuesBufferReuse(t *testing.T) {
+	assert := assert.New(t)
+
+	db := openFakeDB()
+	defer db.Close()
+
+	// Scan targets are allocated once per query, not per row. Clear the pool (which is
+	// cleared after two GCs) so that the buffer put is got by the query.
+	runtime.GC()
+	runtime.GC()
+	buf := &scanBuffer{}
+	scanBufferPool.Put(buf)
+	_, iter, err := QueryValues(context.Background(), db, "2")
+	assert.NoError(err)
+
+	values, err := iter(true)
+	assert.NoError(err)
+	assert.NotNil(values)
+	targets := append([]interface{}(nil), buf.targets...)
+	if len(targets) == 0 {
+		// sync.Pool does not guarantee to return the put buffer.
+		t.Skip("buffer not reused by pool")
+	}
+
+	values, err = iter(true)
+	assert.NoError(err)
+	assert.NotNil(values)
+	for i := range targets {
+		assert.True(targets[i] == buf.targets[i], "target %d", i)
+	}
+	assert.NoError(closeValuesIter(iter))
+}
+
+func closeValuesIter(iter ValuesIter) error {
+	_, err := iter(false)
+	return err
+}
+
+// BenchmarkFakeRows is the baseline of BenchmarkQuery/BenchmarkQueryValues: allocations of
+// the fake driver and database/sql per row, scanning into reused sql.RawBytes.
+func BenchmarkFakeRows(b *testing.B) {
+	db := openFakeDB()
+	defer db.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	// One op per row.
+	rows, err := db.QueryContext(context.Background(), strconv.Itoa(b.N))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rows.Close()
+	targets := make([]interface{}, len(fakeColumns))
+	for i := range targets {
+		targets[i] = new(sql.RawBytes)
+	}
+	for rows.Next() {
+		if err := rows.Scan(targets...); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	db := openFakeDB()
+	defer db.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	// One op per row.
+	iter, err := Query(context.Background(), db, strconv.Itoa(b.N))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer iter(false)
+	for {
+		row, err := iter(true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if row == nil {
+			break
+		}
+	}
+}
+
+func BenchmarkQueryValues(b *testing.B) {
+	db := openFakeDB()
+	defer db.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	// One op per row.
+	_, iter, err := QueryValues(context.Background(), db, strconv.Itoa(b.N))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer iter(false)
+	for {
+		values, err := iter(true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if values == nil {
+			break
+		}
+	}
+}
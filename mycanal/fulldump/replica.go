@@ -0,0 +1,127 @@
+package fulldump
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/logr"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+var (
+	// startReplicaRetries is the max retries to restart the replica SQL thread in LockReplica mode.
+	startReplicaRetries = 5
+)
+
+// startWithReplica stops the replica SQL thread, starts the snapshot without lock, and restarts
+// the SQL thread, which is always restarted (if it was running) no matter what happens.
+//
+// Replication statements cause an implicit commit, so they are run on a separate connection other
+// than the snapshot ones.
+func (s *snapshot) startWithReplica(logger logr.Logger) (err error) {
+
+	bgCtx := context.Background()
+	conn, err := s.db.Conn(bgCtx)
+	if err != nil {
+		return errors.WithMessage(err, "open conn error")
+	}
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	status, err := replicaStatus(bgCtx, conn)
+	if err != nil {
+		return err
+	}
+
+	if status["Slave_SQL_Running"].String == "Yes" || status["Replica_SQL_Running"].String == "Yes" {
+		if _, err = conn.ExecContext(bgCtx, "STOP SLAVE SQL_THREAD"); err != nil {
+			return errors.WithMessage(err, "stop replica sql thread error")
+		}
+		logger.Info("fulldump replica sql thread stopped")
+
+		defer func() {
+			var startErr error
+			if conn, startErr = startReplica(s.db, conn); startErr != nil {
+				logger.Error(startErr, "fulldump restart replica sql thread failed, pls start it manually")
+				if err == nil {
+					s.rollbackTrx()
+					err = startErr
+				}
+				return
+			}
+			logger.Info("fulldump replica sql thread restarted")
+		}()
+
+	} else {
+		// Already stopped by others, leave it as it is.
+		logger.Info("fulldump replica sql thread is not running")
+	}
+
+	// The applier is stopped, so GTID_EXECUTED is stable now.
+	return s.startWithoutLock()
+}
+
+// startReplica starts the replica SQL thread with retries, each retry uses a fresh connection in case
+// conn is broken. It returns the connection used if succeeded, which should be closed by the caller.
+func startReplica(db *sql.DB, conn *sql.Conn) (*sql.Conn, error) {
+	bgCtx := context.Background()
+	var err error
+	for i := 0; i <= startReplicaRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * 100 * time.Millisecond)
+		}
+		if conn == nil {
+			if conn, err = db.Conn(bgCtx); err != nil {
+				continue
+			}
+		}
+		if _, err = conn.ExecContext(bgCtx, "START SLAVE SQL_THREAD"); err == nil {
+			return conn, nil
+		}
+		// The connection may be broken, retry with a fresh one.
+		conn.Close()
+		conn = nil
+	}
+	return nil, errors.WithMessage(err, "start replica sql thread error")
+}
+
+// replicaStatus returns the result of 'SHOW SLAVE STATUS' (single source) as a map.
+func replicaStatus(ctx context.Context, q sqlh.Queryer) (map[string]sql.NullString, error) {
+	rows, err := q.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return nil, errors.WithMessage(err, "show replica status error")
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, errors.WithMessage(err, "show replica status error")
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, errors.WithMessage(err, "show replica status error")
+		}
+		return nil, errors.New("not a replica")
+	}
+
+	values := make([]sql.NullString, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, errors.WithMessage(err, "show replica status error")
+	}
+
+	ret := make(map[string]sql.NullString, len(names))
+	for i, name := range names {
+		ret[name] = values[i]
+	}
+	return ret, nil
+}
@@ -0,0 +1,187 @@
+package fulldump
+
+import (
+	"context"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+var (
+	// SystemSchemas are skipped in ListTables/DumpSchemas.
+	SystemSchemas = []string{"mysql", "information_schema", "performance_schema", "sys"}
+)
+
+// TableFilter is used to select tables in ListTables/DumpSchemas.
+//
+// Patterns are matched against "schema.table" using path.Match, e.g. "db.*", "*.user_*".
+type TableFilter struct {
+	// Include selects tables matching any of the patterns. Empty to select all tables.
+	Include []string
+
+	// Exclude drops tables matching any of the patterns.
+	Exclude []string
+}
+
+// TableInfo contains information of a table.
+type TableInfo struct {
+	TableRef
+
+	// EstimatedRows is the estimated row count from information_schema.TABLES.TABLE_ROWS.
+	EstimatedRows int64
+}
+
+// SchemaHandler is used in DumpSchemas to handle events, can be one of the followings:
+//
+//   - *TableBeginning: the beginning of a table
+//   - *TableRows: rows of the table, the handler should iterate its Iter (no need to close it)
+//   - *TableEnding: the end of a table
+type SchemaHandler func(ctx context.Context, e interface{}) error
+
+// TableBeginning represents the beginning of a table.
+type TableBeginning TableInfo
+
+// TableRows contains rows of a table.
+type TableRows struct {
+	*TableInfo
+
+	// Iter iterates rows of the table.
+	Iter RowIter
+}
+
+// TableEnding represents the end of a table.
+type TableEnding struct {
+	*TableInfo
+
+	// Rows is the number of rows iterated.
+	Rows int64
+}
+
+// ListTables returns base tables (views and tables in SystemSchemas are skipped) selected by filter,
+// ordered by schema and table name. filter can be nil to select all.
+func ListTables(ctx context.Context, q sqlh.Queryer, filter *TableFilter) ([]*TableInfo, error) {
+
+	if filter == nil {
+		filter = &TableFilter{}
+	}
+	for _, pattern := range append(append([]string{}, filter.Include...), filter.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.WithMessagef(err, "fulldump.ListTables bad pattern %q", pattern)
+		}
+	}
+
+	query := "SELECT TABLE_SCHEMA, TABLE_NAME, IFNULL(TABLE_ROWS, 0) FROM information_schema.TABLES " +
+		"WHERE TABLE_TYPE='BASE TABLE' AND TABLE_SCHEMA NOT IN ("
+	args := []interface{}{}
+	for i, schema := range SystemSchemas {
+		if i != 0 {
+			query += ", "
+		}
+		query += "?"
+		args = append(args, schema)
+	}
+	query += ") ORDER BY TABLE_SCHEMA, TABLE_NAME"
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "fulldump.ListTables query error")
+	}
+	defer rows.Close()
+
+	ret := []*TableInfo{}
+	for rows.Next() {
+		table := &TableInfo{}
+		if err := rows.Scan(&table.Schema, &table.Table, &table.EstimatedRows); err != nil {
+			return nil, errors.WithMessage(err, "fulldump.ListTables scan error")
+		}
+		if filter.match(table.Schema + "." + table.Table) {
+			ret = append(ret, table)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithMessage(err, "fulldump.ListTables rows error")
+	}
+	return ret, nil
+}
+
+// DumpSchemas dumps all tables selected by filter (see ListTables) one by one.
+// It should be called inside Handler so that all tables are dumped in the same snapshot.
+//
+// opts (optional) is used to query rows of each table, with Table set to the table. In the handler of
+// FullDumpOpts, use Options.QueryOptions() (plus ColumnRules if needed) to apply the same value options.
+func DumpSchemas(ctx context.Context, q sqlh.Queryer, filter *TableFilter, opts *QueryOptions, handler SchemaHandler) error {
+
+	tables, err := ListTables(ctx, q, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := dumpTable(ctx, q, table, opts, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dumpTable(ctx context.Context, q sqlh.Queryer, table *TableInfo, opts *QueryOptions, handler SchemaHandler) error {
+
+	if err := handler(ctx, (*TableBeginning)(table)); err != nil {
+		return err
+	}
+
+	tq := &TableQuery{Table: table.TableRef}
+	if opts != nil {
+		tq.Canonical = opts.Canonical
+		tq.TimeAsString = opts.TimeAsString
+		tq.ValueMapper = opts.ValueMapper
+		tq.ColumnRules = opts.ColumnRules
+	}
+	iter, err := tq.Query(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer iter(false)
+
+	n := int64(0)
+	if err := handler(ctx, &TableRows{
+		TableInfo: table,
+		Iter: func(next bool) (map[string]interface{}, error) {
+			row, err := iter(next)
+			if row != nil {
+				n++
+			}
+			return row, err
+		},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := iter(false); err != nil {
+		return err
+	}
+
+	return handler(ctx, &TableEnding{
+		TableInfo: table,
+		Rows:      n,
+	})
+}
+
+func (filter *TableFilter) match(name string) bool {
+	for _, pattern := range filter.Exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	if len(filter.Include) == 0 {
+		return true
+	}
+	for _, pattern := range filter.Include {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package fulldump
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, testCase := range []struct {
+		Filter *TableFilter
+		Name   string
+		Expect bool
+	}{
+		{&TableFilter{}, "db.t", true},
+		{&TableFilter{Include: []string{"db.*"}}, "db.t", true},
+		{&TableFilter{Include: []string{"db.*"}}, "db2.t", false},
+		{&TableFilter{Include: []string{"db.*", "db2.t"}}, "db2.t", true},
+		{&TableFilter{Exclude: []string{"*.log_*"}}, "db.log_1", false},
+		{&TableFilter{Exclude: []string{"*.log_*"}}, "db.user", true},
+		{&TableFilter{Include: []string{"db.*"}, Exclude: []string{"db.tmp"}}, "db.tmp", false},
+	} {
+		assert.Equal(testCase.Expect, testCase.Filter.match(testCase.Name), "test case %d", i)
+	}
+}
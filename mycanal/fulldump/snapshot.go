@@ -0,0 +1,283 @@
+package fulldump
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+// LockMode is the locking strategy used to get a consistent snapshot along with its GTID set.
+type LockMode int
+
+const (
+	// LockFTWRL uses 'FLUSH TABLES WITH READ LOCK', which needs RELOAD privilege.
+	// It stalls all writes (and may wait for long running queries) until the snapshot started.
+	// This is the default.
+	LockFTWRL LockMode = iota
+
+	// LockAuto tries LockBackup first and falls back to LockFTWRL if it fails
+	// (e.g. lack of BACKUP_ADMIN privilege or GTID_EXECUTED keeps changing).
+	LockAuto
+
+	// LockBackup uses 'LOCK INSTANCE FOR BACKUP' (MySQL-8.0+, needs BACKUP_ADMIN privilege) to
+	// prevent DDL, and reads GTID_EXECUTED from performance_schema.log_status before and after
+	// starting the snapshot; it retries if they are not the same. Writes are not blocked.
+	LockBackup
+
+	// LockNone takes no lock. It is only for servers without writes, e.g. a replica with SQL thread stopped.
+	// An error is returned if GTID_EXECUTED changes during starting the snapshot.
+	LockNone
+
+	// LockReplica is for taking snapshots from a replica (needs REPLICATION_SLAVE_ADMIN or SUPER privilege).
+	// It stops the replica SQL thread, starts the snapshot as LockNone, and restarts the SQL thread
+	// (if it was running). The SQL thread is always restarted even if starting the snapshot failed.
+	// The SQL thread is controlled through one extra connection, which must be allowed by cfg.MaxOpenConns if set.
+	//
+	// The GTID set can be used to stream binlog from either the replica (log_replica_updates
+	// must be on) or its source. See mycanal.PreflightOptions.LockReplica for checking the privilege.
+	LockReplica
+)
+
+var (
+	// backupLockRetries is the max retries in LockBackup mode.
+	backupLockRetries = 10
+)
+
+// String returns the name of the lock mode.
+func (mode LockMode) String() string {
+	switch mode {
+	case LockFTWRL:
+		return "ftwrl"
+	case LockAuto:
+		return "auto"
+	case LockBackup:
+		return "backup"
+	case LockNone:
+		return "none"
+	case LockReplica:
+		return "replica"
+	default:
+		return "unknown"
+	}
+}
+
+// snapshot is a group of connections sharing the same consistent snapshot.
+type snapshot struct {
+	db      *sql.DB
+	conns   []*sql.Conn
+	gtidSet string
+}
+
+// openSnapshot opens n (>= 1) connections and starts a trx with consistent snapshot on each of them,
+// so that all of them see the same data at GTID_EXECUTED.
+//
+// ref: https://github.com/mydumper/mydumper
+func openSnapshot(ctx context.Context, cfg *Config, opts *Options, n int) (s *snapshot, err error) {
+
+	// Some commands does not need cancel.
+	bgCtx := context.Background()
+
+	s = &snapshot{}
+	defer func() {
+		if err != nil {
+			s.Close()
+			s = nil
+		}
+	}()
+
+	s.db, err = cfg.Client()
+	if err != nil {
+		return nil, errors.WithMessage(err, "open client error")
+	}
+
+	for i := 0; i < n; i++ {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return nil, errors.WithMessage(err, "open conn error")
+		}
+		s.conns = append(s.conns, conn)
+
+		// Set isolation level to repeatable read (the default).
+		if _, err = conn.ExecContext(bgCtx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return nil, errors.WithMessage(err, "set isolation level error")
+		}
+	}
+
+	logger := opts.logger()
+	switch mode := opts.lockMode(); mode {
+	case LockFTWRL:
+		err = s.startWithFTWRL()
+
+	case LockBackup:
+		err = s.startWithBackupLock()
+
+	case LockNone:
+		err = s.startWithoutLock()
+
+	case LockReplica:
+		err = s.startWithReplica(logger)
+
+	case LockAuto:
+		err = s.startWithBackupLock()
+		if err != nil {
+			logger.Error(err, "fulldump backup lock failed, fallback to ftwrl")
+			err = s.startWithFTWRL()
+		}
+
+	default:
+		err = errors.Errorf("unknown lock mode %d", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.gtidSet == "" {
+		return nil, errors.Errorf("No GTID_EXECUTED, pls make sure you have turn on binlog and gtid mode")
+	}
+	logger.Info("fulldump snapshot started", "gtidSet", s.gtidSet)
+	return s, nil
+}
+
+func (s *snapshot) startWithFTWRL() (err error) {
+
+	bgCtx := context.Background()
+	lockConn := s.conns[0]
+
+	// 1. Lock tables: to get current GTID set and start trx.
+	// NOTE: the lock will be released if connection closed.
+	_, err = lockConn.ExecContext(bgCtx, "FLUSH TABLES WITH READ LOCK")
+	if err != nil {
+		return errors.WithMessage(err, "ftwrl error")
+	}
+	defer func() {
+		// XXX: to ensure unlock is run
+		lockConn.ExecContext(bgCtx, "UNLOCK TABLES")
+	}()
+
+	// 2. Start trx with consistent snapshot on all connections.
+	if err = s.startTrx(); err != nil {
+		return err
+	}
+
+	// 3. Get GTID_EXECUTED
+	err = lockConn.QueryRowContext(bgCtx, "SELECT @@GLOBAL.GTID_EXECUTED").Scan(&s.gtidSet)
+	if err != nil {
+		s.rollbackTrx()
+		return errors.WithMessage(err, "get gtid error")
+	}
+
+	// 4. Unlock tables.
+	_, err = lockConn.ExecContext(bgCtx, "UNLOCK TABLES")
+	if err != nil {
+		s.rollbackTrx()
+		return errors.WithMessage(err, "unlock tables error")
+	}
+
+	return nil
+}
+
+func (s *snapshot) startWithBackupLock() (err error) {
+
+	bgCtx := context.Background()
+	lockConn := s.conns[0]
+
+	// Prevent DDL (and other operations that can't be done during backup).
+	_, err = lockConn.ExecContext(bgCtx, "LOCK INSTANCE FOR BACKUP")
+	if err != nil {
+		return errors.WithMessage(err, "lock instance for backup error")
+	}
+	defer func() {
+		lockConn.ExecContext(bgCtx, "UNLOCK INSTANCE")
+	}()
+
+	// Querying log_status blocks commits so that the returned GTID_EXECUTED is
+	// consistent with committed data.
+	const query = "SELECT JSON_UNQUOTE(JSON_EXTRACT(LOCAL, '$.gtid_executed')) FROM performance_schema.log_status"
+
+	for i := 0; ; i++ {
+		var before, after string
+		if err = lockConn.QueryRowContext(bgCtx, query).Scan(&before); err != nil {
+			return errors.WithMessage(err, "query log_status error")
+		}
+
+		if err = s.startTrx(); err != nil {
+			return err
+		}
+
+		if err = lockConn.QueryRowContext(bgCtx, query).Scan(&after); err != nil {
+			s.rollbackTrx()
+			return errors.WithMessage(err, "query log_status error")
+		}
+
+		// No trx committed during starting snapshot.
+		if before == after {
+			s.gtidSet = after
+			return nil
+		}
+
+		s.rollbackTrx()
+		if i >= backupLockRetries {
+			return errors.Errorf("GTID_EXECUTED keeps changing after %d retries", i)
+		}
+	}
+}
+
+func (s *snapshot) startWithoutLock() (err error) {
+
+	bgCtx := context.Background()
+	conn := s.conns[0]
+
+	var before, after string
+	if err = conn.QueryRowContext(bgCtx, "SELECT @@GLOBAL.GTID_EXECUTED").Scan(&before); err != nil {
+		return errors.WithMessage(err, "get gtid error")
+	}
+
+	if err = s.startTrx(); err != nil {
+		return err
+	}
+
+	if err = conn.QueryRowContext(bgCtx, "SELECT @@GLOBAL.GTID_EXECUTED").Scan(&after); err != nil {
+		s.rollbackTrx()
+		return errors.WithMessage(err, "get gtid error")
+	}
+
+	if before != after {
+		s.rollbackTrx()
+		return errors.Errorf("GTID_EXECUTED changed during starting snapshot, pls make sure there is no write to the server")
+	}
+
+	s.gtidSet = after
+	return nil
+}
+
+// startTrx starts trx with consistent snapshot on all connections.
+func (s *snapshot) startTrx() error {
+	for _, conn := range s.conns {
+		if _, err := conn.ExecContext(context.Background(), "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			s.rollbackTrx()
+			return errors.WithMessage(err, "start transaction error")
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) rollbackTrx() {
+	for _, conn := range s.conns {
+		// fulldump should not modify data
+		conn.ExecContext(context.Background(), "ROLLBACK")
+	}
+}
+
+// Close rollbacks all trxs and releases connections.
+func (s *snapshot) Close() {
+	s.rollbackTrx()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	if s.db != nil {
+		s.db.Close()
+	}
+}
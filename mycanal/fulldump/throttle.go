@@ -0,0 +1,252 @@
+package fulldump
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/logr"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+var (
+	// DefaultProbeInterval is the default value of Throttle.ProbeInterval.
+	DefaultProbeInterval = time.Second
+
+	// DefaultMaxPause is the default value of Throttle.MaxPause.
+	DefaultMaxPause = 30 * time.Second
+)
+
+// Probe returns a load metric of a server, e.g. Threads_running or replica lag in seconds.
+type Probe func(ctx context.Context) (float64, error)
+
+// Throttle limits the reading speed of rows. It is safe for concurrent use and the limits are
+// shared by all iterators wrapped by it. A Throttle must not be copied after first use.
+//
+// Throttling only pauses reading rows, the snapshot transaction is kept. But if a result set
+// is not read for too long (net_write_timeout, default 60s), MySQL aborts the connection,
+// so a pause lasts at most MaxPause before another row is read.
+type Throttle struct {
+	// RowsPerSecond caps the number of rows read per second. 0 for no limit.
+	RowsPerSecond float64
+
+	// BytesPerSecond caps the (approximate) size of values read per second. 0 for no limit.
+	BytesPerSecond float64
+
+	// Probe, if not nil, is called (at most once every ProbeInterval) to get the load of the server.
+	// Reading pauses while the result is greater than ProbeThreshold.
+	//
+	// NOTE: Probe should use a connection (pool) other than the snapshot's.
+	Probe Probe
+
+	// ProbeThreshold is the max acceptable result of Probe.
+	ProbeThreshold float64
+
+	// ProbeInterval is the min interval between probes, and the initial backoff when paused.
+	// Backoff doubles while the server stays overloaded.
+	//
+	// Use DefaultProbeInterval if not set.
+	ProbeInterval time.Duration
+
+	// MaxPause is the max continuous pause, a row is read after that anyway.
+	//
+	// Use DefaultMaxPause if not set.
+	MaxPause time.Duration
+
+	// Logger for logging.
+	//
+	// Use DefaultLogger if not set.
+	Logger logr.Logger
+
+	mu        sync.Mutex
+	next      time.Time // when the next row can be read
+	probedAt  time.Time
+	probing   bool // whether a probe is running
+	overload  bool
+	lastProbe float64
+}
+
+// Wrap returns a RowIter throttled by t. ctx is used for waiting.
+func (t *Throttle) Wrap(ctx context.Context, iter RowIter) RowIter {
+	return func(next bool) (map[string]interface{}, error) {
+		if next {
+			if err := t.waitProbe(ctx); err != nil {
+				return nil, err
+			}
+		}
+		row, err := iter(next)
+		if row != nil {
+			if err := t.waitRate(ctx, rowSize(row)); err != nil {
+				return nil, err
+			}
+		}
+		return row, err
+	}
+}
+
+// waitRate waits for the rate limits after a row of size bytes is read.
+func (t *Throttle) waitRate(ctx context.Context, size int64) error {
+	cost := time.Duration(0)
+	if t.RowsPerSecond > 0 {
+		cost = time.Duration(float64(time.Second) / t.RowsPerSecond)
+	}
+	if t.BytesPerSecond > 0 {
+		if c := time.Duration(float64(time.Second) * float64(size) / t.BytesPerSecond); c > cost {
+			cost = c
+		}
+	}
+	if cost == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(cost)
+	wait := t.next.Sub(now)
+	t.mu.Unlock()
+
+	// Avoid too many tiny sleeps.
+	if wait < 10*time.Millisecond {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
+
+// waitProbe waits until the server is not overloaded or MaxPause reached.
+func (t *Throttle) waitProbe(ctx context.Context) error {
+	if t.Probe == nil {
+		return nil
+	}
+
+	interval := t.probeInterval()
+	maxPause := t.maxPause()
+	backoff := interval
+	paused := time.Duration(0)
+	for {
+		overload, load := t.probe(ctx, interval)
+		if !overload {
+			if paused > 0 {
+				t.logger().Info("fulldump throttle resume", "paused", paused.String(), "load", load)
+			}
+			return nil
+		}
+		if paused >= maxPause {
+			t.logger().Info("fulldump throttle max pause reached", "paused", paused.String(), "load", load)
+			return nil
+		}
+
+		if backoff > maxPause-paused {
+			backoff = maxPause - paused
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		paused += backoff
+		backoff *= 2
+	}
+}
+
+// probe returns whether the server is overloaded, using the cached result if probed recently
+// or another probe is running. Probe is called without holding t.mu.
+func (t *Throttle) probe(ctx context.Context, interval time.Duration) (overload bool, load float64) {
+	t.mu.Lock()
+	if t.probing || time.Since(t.probedAt) < interval {
+		overload, load = t.overload, t.lastProbe
+		t.mu.Unlock()
+		return
+	}
+	t.probing = true
+	t.mu.Unlock()
+
+	load, err := t.Probe(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+	t.probedAt = time.Now()
+	if err != nil {
+		// Don't fail a long running dump because of probe errors.
+		t.logger().Error(err, "fulldump throttle probe error")
+		t.overload = false
+		t.lastProbe = 0
+		return false, 0
+	}
+	t.lastProbe = load
+	t.overload = load > t.ProbeThreshold
+	return t.overload, load
+}
+
+func (t *Throttle) probeInterval() time.Duration {
+	if t.ProbeInterval > 0 {
+		return t.ProbeInterval
+	}
+	return DefaultProbeInterval
+}
+
+func (t *Throttle) maxPause() time.Duration {
+	if t.MaxPause > 0 {
+		return t.MaxPause
+	}
+	return DefaultMaxPause
+}
+
+func (t *Throttle) logger() logr.Logger {
+	if t.Logger != nil {
+		return t.Logger
+	}
+	return DefaultLogger
+}
+
+// ThreadsRunning returns a Probe returning the Threads_running status of the server.
+func ThreadsRunning(q sqlh.Queryer) Probe {
+	return func(ctx context.Context) (float64, error) {
+		var name, value string
+		if err := q.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Threads_running'").Scan(&name, &value); err != nil {
+			return 0, errors.WithMessage(err, "fulldump.ThreadsRunning")
+		}
+		ret, err := strconv.ParseFloat(value, 64)
+		return ret, errors.WithMessage(err, "fulldump.ThreadsRunning")
+	}
+}
+
+// ReplicaLag returns a Probe returning Seconds_Behind_Master of a replica (q should connect to
+// the replica). +Inf is returned if replication is not running.
+func ReplicaLag(q sqlh.Queryer) Probe {
+	return func(ctx context.Context) (float64, error) {
+		status, err := replicaStatus(ctx, q)
+		if err != nil {
+			return 0, errors.WithMessage(err, "fulldump.ReplicaLag")
+		}
+
+		for _, name := range []string{"Seconds_Behind_Master", "Seconds_Behind_Source"} {
+			value, ok := status[name]
+			if !ok {
+				continue
+			}
+			if !value.Valid {
+				return math.Inf(1), nil
+			}
+			ret, err := strconv.ParseFloat(value.String, 64)
+			return ret, errors.WithMessage(err, "fulldump.ReplicaLag")
+		}
+		return 0, errors.New("fulldump.ReplicaLag: no Seconds_Behind_Master")
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,158 @@
+package fulldump
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	assert := assert.New(t)
+
+	rows := func(n int) RowIter {
+		return func(next bool) (map[string]interface{}, error) {
+			if !next || n == 0 {
+				return nil, nil
+			}
+			n--
+			return map[string]interface{}{"a": "0123456789"}, nil
+		}
+	}
+	drain := func(iter RowIter) (int, time.Duration, error) {
+		start := time.Now()
+		n := 0
+		for {
+			row, err := iter(true)
+			if err != nil || row == nil {
+				return n, time.Since(start), err
+			}
+			n++
+		}
+	}
+	ctx := context.Background()
+
+	// Rows per second.
+	{
+		n, elapsed, err := drain((&Throttle{RowsPerSecond: 100}).Wrap(ctx, rows(20)))
+		assert.NoError(err)
+		assert.Equal(20, n)
+		assert.True(elapsed >= 150*time.Millisecond, elapsed.String())
+	}
+
+	// Bytes per second: 10 bytes per row.
+	{
+		n, elapsed, err := drain((&Throttle{BytesPerSecond: 1000}).Wrap(ctx, rows(20)))
+		assert.NoError(err)
+		assert.Equal(20, n)
+		assert.True(elapsed >= 150*time.Millisecond, elapsed.String())
+	}
+
+	// Probe: overloaded for the first 3 probes.
+	{
+		probes := 0
+		throttle := &Throttle{
+			Probe: func(ctx context.Context) (float64, error) {
+				probes++
+				if probes <= 3 {
+					return 10, nil
+				}
+				return 1, nil
+			},
+			ProbeThreshold: 5,
+			ProbeInterval:  10 * time.Millisecond,
+		}
+		n, elapsed, err := drain(throttle.Wrap(ctx, rows(3)))
+		assert.NoError(err)
+		assert.Equal(3, n)
+		// Backoff 10ms + 20ms + 40ms.
+		assert.True(elapsed >= 70*time.Millisecond, elapsed.String())
+	}
+
+	// Probe: always overloaded but MaxPause reached.
+	{
+		throttle := &Throttle{
+			Probe: func(ctx context.Context) (float64, error) {
+				return 10, nil
+			},
+			ProbeThreshold: 5,
+			ProbeInterval:  10 * time.Millisecond,
+			MaxPause:       50 * time.Millisecond,
+		}
+		n, elapsed, err := drain(throttle.Wrap(ctx, rows(2)))
+		assert.NoError(err)
+		assert.Equal(2, n)
+		assert.True(elapsed >= 100*time.Millisecond, elapsed.String())
+	}
+
+	// Probe errors are ignored.
+	{
+		throttle := &Throttle{
+			Probe: func(ctx context.Context) (float64, error) {
+				return 0, errors.New("probe error")
+			},
+		}
+		n, _, err := drain(throttle.Wrap(ctx, rows(2)))
+		assert.NoError(err)
+		assert.Equal(2, n)
+	}
+
+	// Cancel.
+	{
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, _, err := drain((&Throttle{RowsPerSecond: 10}).Wrap(ctx, rows(20)))
+		assert.Equal(context.DeadlineExceeded, err)
+	}
+}
+
+func TestThrottleProbeConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	var probes int32
+	release := make(chan struct{})
+	throttle := &Throttle{
+		RowsPerSecond: 1000,
+		Probe: func(ctx context.Context) (float64, error) {
+			atomic.AddInt32(&probes, 1)
+			<-release
+			return 0, nil
+		},
+		ProbeInterval: time.Hour,
+	}
+	ctx := context.Background()
+
+	// Only one probe runs at a time, others use the cached result.
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(throttle.waitProbe(ctx))
+	}()
+	for atomic.LoadInt32(&probes) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		assert.NoError(throttle.waitProbe(ctx))
+	}
+
+	// Rate limiting is not blocked by the running probe.
+	done := make(chan error, 1)
+	go func() {
+		done <- throttle.waitRate(ctx, 0)
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("waitRate blocked by probe")
+	}
+
+	close(release)
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&probes))
+}
@@ -4,258 +4,299 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"strings"
 
-	"github.com/go-sql-driver/mysql"
 	"gopkg.in/volatiletech/null.v6"
+
+	. "github.com/huangjunwen/golibs/mycanal"
 )
 
-func makeScanValues(rows *sql.Rows) func([]interface{}) []interface{} {
+// columnInfo is the metadata of a result column to choose scan type.
+type columnInfo struct {
+	// typeName is from ColumnType.DatabaseTypeName, e.g. "INT", "VARCHAR", "UNSIGNED INT".
+	typeName string
 
-	// XXX: Using ColumnType.ScanType can't handle extreme large value for BIGINT UNSIGNED DEFAULT NULL
-	// columns because 'github.com/go-sql-driver/mysql' uses sql.NullInt64.
-	// And there is no way to know unsigned flag from public api of 'database/sql'.
-	// So we have to query the private fields here.
+	// nullable is from ColumnType.Nullable.
+	nullable bool
 
-	intlCols := reflect.
-		ValueOf(rows).         // *sql.Rows
-		Elem().                // sql.Rows
-		FieldByName("rowsi").  // driver.Rows
-		Elem().                // *mysql.textRows or *mysql.binaryRows
-		Elem().                // mysql.textRows or mysql.binaryRows
-		FieldByName("rs").     // mysql.resultSet
-		FieldByName("columns") // []mysql.mysqlField
+	// scanType is from ColumnType.ScanType. 'github.com/go-sql-driver/mysql' reports unsigned types for
+	// NOT NULL unsigned integer columns.
+	scanType reflect.Type
 
-	n := intlCols.Len()
-	fns := make([]func() interface{}, 0, n)
+	// unsigned is from information_schema if the source table is known.
+	unsigned bool
+}
 
-	for i := 0; i < n; i++ {
+func newColumnInfo(colType *sql.ColumnType, unsigned bool) columnInfo {
+	nullable, ok := colType.Nullable()
+	if !ok {
+		nullable = true
+	}
+	return columnInfo{
+		typeName: colType.DatabaseTypeName(),
+		nullable: nullable,
+		scanType: colType.ScanType(),
+		unsigned: unsigned,
+	}
+}
 
-		intlCol := intlCols.Index(i)
-		fieldType := fieldType(intlCol.FieldByName("fieldType").Uint())
-		flags := fieldFlag(intlCol.FieldByName("flags").Uint())
+// makeScanValues returns a function to append scan targets for columns.
+//
+// NOTE: Using ColumnType.ScanType can't handle extreme large value for BIGINT UNSIGNED DEFAULT NULL
+// columns because 'github.com/go-sql-driver/mysql' uses sql.NullInt64. So signedness is collected from
+// DatabaseTypeName ("UNSIGNED " prefix), ScanType and information_schema (if the source table is known).
+func makeScanValues(cols []columnInfo) (func([]interface{}) []interface{}, error) {
+
+	fns := make([]func() interface{}, 0, len(cols))
+	for _, col := range cols {
+		fn, err := scanValueFn(col)
+		if err != nil {
+			return nil, err
+		}
+		fns = append(fns, fn)
+	}
 
-		// The following are copied and modified from github.com/go-sql-driver/mysql@v1.5.0/fields.go mysqlField.scanType
+	return func(slice []interface{}) []interface{} {
+		slice = slice[:0]
+		for _, fn := range fns {
+			slice = append(slice, fn())
+		}
+		return slice
+	}, nil
+}
 
-		notNull := (flags & flagNotNULL) != 0
-		unsigned := (flags & flagUnsigned) != 0
+func scanValueFn(col columnInfo) (func() interface{}, error) {
 
-		switch fieldType {
-		case fieldTypeTiny:
-			if notNull {
-				if unsigned {
-					fns = append(fns, newUint8)
-				} else {
-					fns = append(fns, newInt8)
-				}
-			} else {
-				if unsigned {
-					fns = append(fns, newNullUint8)
-				} else {
-					fns = append(fns, newNullInt8)
-				}
-			}
+	typeName := col.typeName
+	notNull := !col.nullable
+	unsigned := col.unsigned
+	if strings.HasPrefix(typeName, "UNSIGNED ") {
+		typeName = strings.TrimPrefix(typeName, "UNSIGNED ")
+		unsigned = true
+	}
+	if col.scanType != nil {
+		switch col.scanType.Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			unsigned = true
+		}
+	}
 
-		case fieldTypeShort, fieldTypeYear:
-			if notNull {
-				if unsigned {
-					fns = append(fns, newUint16)
-				} else {
-					fns = append(fns, newInt16)
-				}
-			} else {
-				if unsigned {
-					fns = append(fns, newNullUint16)
-				} else {
-					fns = append(fns, newNullInt16)
-				}
-			}
+	// The following are modified from github.com/go-sql-driver/mysql@v1.5.0/fields.go mysqlField.scanType
 
-		case fieldTypeInt24, fieldTypeLong:
-			if notNull {
-				if unsigned {
-					fns = append(fns, newUint32)
-				} else {
-					fns = append(fns, newInt32)
-				}
-			} else {
-				if unsigned {
-					fns = append(fns, newNullUint32)
-				} else {
-					fns = append(fns, newNullInt32)
-				}
+	switch typeName {
+	case "TINYINT":
+		if notNull {
+			if unsigned {
+				return newUint8, nil
 			}
+			return newInt8, nil
+		}
+		if unsigned {
+			return newNullUint8, nil
+		}
+		return newNullInt8, nil
 
-		case fieldTypeLongLong:
-			if notNull {
-				if unsigned {
-					fns = append(fns, newUint64)
-				} else {
-					fns = append(fns, newInt64)
-				}
-			} else {
-				if unsigned {
-					fns = append(fns, newNullUint64)
-				} else {
-					fns = append(fns, newNullInt64)
-				}
+	case "SMALLINT", "YEAR":
+		// NOTE: YEAR is always unsigned.
+		if typeName == "YEAR" {
+			unsigned = true
+		}
+		if notNull {
+			if unsigned {
+				return newUint16, nil
 			}
+			return newInt16, nil
+		}
+		if unsigned {
+			return newNullUint16, nil
+		}
+		return newNullInt16, nil
 
-		case fieldTypeFloat:
-			if notNull {
-				fns = append(fns, newFloat32)
-			} else {
-				fns = append(fns, newNullFloat32)
+	case "MEDIUMINT", "INT":
+		if notNull {
+			if unsigned {
+				return newUint32, nil
 			}
+			return newInt32, nil
+		}
+		if unsigned {
+			return newNullUint32, nil
+		}
+		return newNullInt32, nil
 
-		case fieldTypeDouble:
-			if notNull {
-				fns = append(fns, newFloat64)
-			} else {
-				fns = append(fns, newNullFloat64)
+	case "BIGINT":
+		if notNull {
+			if unsigned {
+				return newUint64, nil
 			}
+			return newInt64, nil
+		}
+		if unsigned {
+			return newNullUint64, nil
+		}
+		return newNullInt64, nil
 
-		case fieldTypeDecimal, fieldTypeNewDecimal, fieldTypeVarChar,
-			fieldTypeBit, fieldTypeEnum, fieldTypeSet, fieldTypeTinyBLOB,
-			fieldTypeMediumBLOB, fieldTypeLongBLOB, fieldTypeBLOB,
-			fieldTypeVarString, fieldTypeString, fieldTypeGeometry, fieldTypeJSON,
-			fieldTypeTime:
-			fns = append(fns, newNullString)
+	case "FLOAT":
+		if notNull {
+			return newFloat32, nil
+		}
+		return newNullFloat32, nil
 
-		case fieldTypeDate, fieldTypeNewDate,
-			fieldTypeTimestamp, fieldTypeDateTime:
-			fns = append(fns, newNullTime)
+	case "DOUBLE":
+		if notNull {
+			return newFloat64, nil
+		}
+		return newNullFloat64, nil
 
-		default:
-			panic(fmt.Errorf("Don't known field type %d", fieldType))
+	case "DECIMAL", "VARCHAR", "VARBINARY", "BIT", "ENUM", "SET",
+		"TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT",
+		"TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB",
+		"CHAR", "BINARY", "GEOMETRY", "JSON", "TIME":
+		return newNullString, nil
 
-		}
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return newNullTime, nil
+
+	default:
+		return nil, fmt.Errorf("Don't known database type %q", col.typeName)
 	}
+}
 
-	return func(slice []interface{}) []interface{} {
-		slice = slice[:0]
-		for _, fn := range fns {
-			slice = append(slice, fn())
-		}
-		return slice
+// valueKind returns the kind of values of a database type for ValueMapper.
+func valueKind(typeName string) ValueKind {
+	switch typeName {
+	case "DECIMAL":
+		return DecimalValue
+	case "JSON":
+		return JSONValue
+	case "DATETIME":
+		return DatetimeValue
+	case "TIMESTAMP":
+		return TimestampValue
+	case "BIT":
+		return BitValue
+	default:
+		return OtherValue
 	}
 }
 
-func postProcessScanedValues(vals []interface{}) {
+// postProcessScanedValues converts scaned values (src) to output values (dst).
+func postProcessScanedValues(dst, src []interface{}) {
 
-	for i, val := range vals {
+	for i, val := range src {
 		switch v := val.(type) {
 		case *int8:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *uint8:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *null.Int8:
 			if v.Valid {
-				vals[i] = v.Int8
+				dst[i] = v.Int8
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *null.Uint8:
 			if v.Valid {
-				vals[i] = v.Uint8
+				dst[i] = v.Uint8
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *int16:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *uint16:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *null.Int16:
 			if v.Valid {
-				vals[i] = v.Int16
+				dst[i] = v.Int16
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *null.Uint16:
 			if v.Valid {
-				vals[i] = v.Uint16
+				dst[i] = v.Uint16
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *int32:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *uint32:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *null.Int32:
 			if v.Valid {
-				vals[i] = v.Int32
+				dst[i] = v.Int32
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *null.Uint32:
 			if v.Valid {
-				vals[i] = v.Uint32
+				dst[i] = v.Uint32
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *int64:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *uint64:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *null.Int64:
 			if v.Valid {
-				vals[i] = v.Int64
+				dst[i] = v.Int64
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *null.Uint64:
 			if v.Valid {
-				vals[i] = v.Uint64
+				dst[i] = v.Uint64
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *float32:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *null.Float32:
 			if v.Valid {
-				vals[i] = v.Float32
+				dst[i] = v.Float32
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *float64:
-			vals[i] = *v
+			dst[i] = *v
 
 		case *null.Float64:
 			if v.Valid {
-				vals[i] = v.Float64
+				dst[i] = v.Float64
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		case *null.String:
 			if v.Valid {
-				vals[i] = v.String
+				dst[i] = v.String
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
-		case *mysql.NullTime:
+		case *sql.NullTime:
 			if v.Valid {
-				vals[i] = v.Time
+				dst[i] = v.Time
 			} else {
-				vals[i] = nil
+				dst[i] = nil
 			}
 
 		default:
@@ -286,62 +327,4 @@ func newNullFloat32() interface{} { return &null.Float32{} }
 func newFloat64() interface{}     { return new(float64) }
 func newNullFloat64() interface{} { return &null.Float64{} }
 func newNullString() interface{}  { return &null.String{} }
-func newNullTime() interface{}    { return &mysql.NullTime{} }
-
-// The following are copied from github.com/go-sql-driver/mysql@v1.5.0/const.go
-
-type fieldType byte
-
-const (
-	fieldTypeDecimal fieldType = iota
-	fieldTypeTiny
-	fieldTypeShort
-	fieldTypeLong
-	fieldTypeFloat
-	fieldTypeDouble
-	fieldTypeNULL
-	fieldTypeTimestamp
-	fieldTypeLongLong
-	fieldTypeInt24
-	fieldTypeDate
-	fieldTypeTime
-	fieldTypeDateTime
-	fieldTypeYear
-	fieldTypeNewDate
-	fieldTypeVarChar
-	fieldTypeBit
-)
-const (
-	fieldTypeJSON fieldType = iota + 0xf5
-	fieldTypeNewDecimal
-	fieldTypeEnum
-	fieldTypeSet
-	fieldTypeTinyBLOB
-	fieldTypeMediumBLOB
-	fieldTypeLongBLOB
-	fieldTypeBLOB
-	fieldTypeVarString
-	fieldTypeString
-	fieldTypeGeometry
-)
-
-type fieldFlag uint16
-
-const (
-	flagNotNULL fieldFlag = 1 << iota
-	flagPriKey
-	flagUniqueKey
-	flagMultipleKey
-	flagBLOB
-	flagUnsigned
-	flagZeroFill
-	flagBinary
-	flagEnum
-	flagAutoIncrement
-	flagTimestamp
-	flagSet
-	flagUnknown1
-	flagUnknown2
-	flagUnknown3
-	flagUnknown4
-)
+func newNullTime() interface{}    { return &sql.NullTime{} }
@@ -0,0 +1,83 @@
+package fulldump
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+var (
+	scanTypeInt8     = reflect.TypeOf(int8(0))
+	scanTypeUint8    = reflect.TypeOf(uint8(0))
+	scanTypeUint16   = reflect.TypeOf(uint16(0))
+	scanTypeInt64    = reflect.TypeOf(int64(0))
+	scanTypeUint64   = reflect.TypeOf(uint64(0))
+	scanTypeNullInt  = reflect.TypeOf(sql.NullInt64{})
+	scanTypeRawBytes = reflect.TypeOf(sql.RawBytes{})
+	scanTypeNullTime = reflect.TypeOf(sql.NullTime{})
+)
+
+// TestScanValueFn checks scan targets chosen from column metadata, see tst for the ones reported
+// by the driver.
+func TestScanValueFn(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, testCase := range []struct {
+		Col    columnInfo
+		Expect interface{}
+	}{
+		// Signed.
+		{columnInfo{typeName: "TINYINT", nullable: false, scanType: scanTypeInt8}, new(int8)},
+		{columnInfo{typeName: "TINYINT", nullable: true, scanType: scanTypeNullInt}, &null.Int8{}},
+		{columnInfo{typeName: "BIGINT", nullable: true, scanType: scanTypeNullInt}, &null.Int64{}},
+		{columnInfo{typeName: "BIGINT", nullable: false, scanType: scanTypeInt64}, new(int64)},
+
+		// Unsigned NOT NULL.
+		{columnInfo{typeName: "TINYINT", nullable: false, scanType: scanTypeUint8}, new(uint8)},
+		{columnInfo{typeName: "UNSIGNED TINYINT", nullable: false, scanType: scanTypeUint8}, new(uint8)},
+		{columnInfo{typeName: "BIGINT", nullable: false, scanType: scanTypeUint64}, new(uint64)},
+		{columnInfo{typeName: "UNSIGNED BIGINT", nullable: false, scanType: scanTypeUint64}, new(uint64)},
+
+		// Unsigned nullable: from DatabaseTypeName or information_schema.
+		{columnInfo{typeName: "BIGINT", nullable: true, scanType: scanTypeNullInt, unsigned: true}, &null.Uint64{}},
+		{columnInfo{typeName: "UNSIGNED BIGINT", nullable: true, scanType: scanTypeNullInt}, &null.Uint64{}},
+		{columnInfo{typeName: "MEDIUMINT", nullable: true, scanType: scanTypeNullInt, unsigned: true}, &null.Uint32{}},
+		{columnInfo{typeName: "UNSIGNED MEDIUMINT", nullable: true, scanType: scanTypeNullInt}, &null.Uint32{}},
+
+		// YEAR is always unsigned.
+		{columnInfo{typeName: "YEAR", nullable: true, scanType: scanTypeNullInt}, &null.Uint16{}},
+		{columnInfo{typeName: "YEAR", nullable: false, scanType: scanTypeUint16}, new(uint16)},
+
+		// Others.
+		{columnInfo{typeName: "DECIMAL", nullable: false, scanType: scanTypeRawBytes}, &null.String{}},
+		{columnInfo{typeName: "VARBINARY", nullable: true, scanType: scanTypeRawBytes}, &null.String{}},
+		{columnInfo{typeName: "TIME", nullable: true, scanType: scanTypeRawBytes}, &null.String{}},
+		{columnInfo{typeName: "DATETIME", nullable: false, scanType: scanTypeNullTime}, &sql.NullTime{}},
+	} {
+		fn, err := scanValueFn(testCase.Col)
+		assert.NoError(err, "test case %d", i)
+		assert.Equal(testCase.Expect, fn(), "test case %d", i)
+	}
+
+	_, err := scanValueFn(columnInfo{typeName: "XXX"})
+	assert.Error(err)
+}
+
+func TestPostProcessScanedValues(t *testing.T) {
+	assert := assert.New(t)
+
+	src := []interface{}{
+		newUint64(),
+		&null.Uint64{Uint64: 18446744073709551615, Valid: true},
+		&null.Int8{},
+		&null.String{String: "abc", Valid: true},
+	}
+	dst := make([]interface{}, len(src))
+	postProcessScanedValues(dst, src)
+	assert.Equal([]interface{}{uint64(0), uint64(18446744073709551615), nil, "abc"}, dst)
+	assert.Equal("*uint64", fmt.Sprintf("%T", src[0]))
+}
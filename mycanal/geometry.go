@@ -0,0 +1,239 @@
+package mycanal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Geometry is a GEOMETRY value.
+type Geometry struct {
+	// SRID is the spatial reference system id.
+	SRID uint32
+
+	// WKB is the well-known binary representation.
+	WKB []byte
+}
+
+// WKB geometry types.
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+)
+
+// ParseGeometry parses MySQL's internal geometry format: 4 bytes (little endian) SRID followed by WKB.
+func ParseGeometry(data []byte) (Geometry, error) {
+	if len(data) < 4 {
+		return Geometry{}, fmt.Errorf("ParseGeometry: data too short (%d bytes)", len(data))
+	}
+	return Geometry{
+		SRID: binary.LittleEndian.Uint32(data),
+		WKB:  append([]byte(nil), data[4:]...),
+	}, nil
+}
+
+// Bytes returns MySQL's internal geometry format.
+func (g Geometry) Bytes() []byte {
+	ret := make([]byte, 4+len(g.WKB))
+	binary.LittleEndian.PutUint32(ret, g.SRID)
+	copy(ret[4:], g.WKB)
+	return ret
+}
+
+// WKT returns the well-known text representation, e.g. "POINT(1 2)", "POLYGON((0 0,1 0,1 1,0 0))".
+func (g Geometry) WKT() (string, error) {
+	r := &wkbReader{data: g.WKB}
+	b := &strings.Builder{}
+	if err := r.readGeometry(b, true); err != nil {
+		return "", err
+	}
+	if len(r.data) != 0 {
+		return "", fmt.Errorf("Geometry.WKT: %d trailing bytes", len(r.data))
+	}
+	return b.String(), nil
+}
+
+// String returns WKT (with SRID if not 0) or a description if WKB is malformed.
+func (g Geometry) String() string {
+	wkt, err := g.WKT()
+	if err != nil {
+		return fmt.Sprintf("<bad geometry: %s>", err)
+	}
+	if g.SRID != 0 {
+		return fmt.Sprintf("SRID=%d;%s", g.SRID, wkt)
+	}
+	return wkt
+}
+
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) readHeader() (uint32, error) {
+	if len(r.data) < 5 {
+		return 0, fmt.Errorf("Geometry.WKT: unexpected end of WKB")
+	}
+	switch r.data[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return 0, fmt.Errorf("Geometry.WKT: bad byte order %d", r.data[0])
+	}
+	r.data = r.data[1:]
+	return r.readUint32()
+}
+
+func (r *wkbReader) readUint32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, fmt.Errorf("Geometry.WKT: unexpected end of WKB")
+	}
+	ret := r.order.Uint32(r.data)
+	r.data = r.data[4:]
+	return ret, nil
+}
+
+func (r *wkbReader) readCount() (int, error) {
+	n, err := r.readUint32()
+	if err != nil {
+		return 0, err
+	}
+	// Each element takes at least 4 bytes.
+	if int64(n)*4 > int64(len(r.data)) {
+		return 0, fmt.Errorf("Geometry.WKT: bad element count %d", n)
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) readPoint(b *strings.Builder) error {
+	if len(r.data) < 16 {
+		return fmt.Errorf("Geometry.WKT: unexpected end of WKB")
+	}
+	x := math.Float64frombits(r.order.Uint64(r.data))
+	y := math.Float64frombits(r.order.Uint64(r.data[8:]))
+	r.data = r.data[16:]
+	b.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(y, 'f', -1, 64))
+	return nil
+}
+
+// readPoints reads "(x y,x y,...)".
+func (r *wkbReader) readPoints(b *strings.Builder) error {
+	n, err := r.readCount()
+	if err != nil {
+		return err
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		if err := r.readPoint(b); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// readRings reads "((x y,...),(x y,...))".
+func (r *wkbReader) readRings(b *strings.Builder) error {
+	n, err := r.readCount()
+	if err != nil {
+		return err
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		if err := r.readPoints(b); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// readGeometry reads a geometry (with header). Type name is written only if withName is true.
+func (r *wkbReader) readGeometry(b *strings.Builder, withName bool) error {
+	typ, err := r.readHeader()
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	var body func() error
+	switch typ {
+	case wkbPoint:
+		name = "POINT"
+		body = func() error {
+			b.WriteByte('(')
+			if err := r.readPoint(b); err != nil {
+				return err
+			}
+			b.WriteByte(')')
+			return nil
+		}
+
+	case wkbLineString:
+		name = "LINESTRING"
+		body = func() error { return r.readPoints(b) }
+
+	case wkbPolygon:
+		name = "POLYGON"
+		body = func() error { return r.readRings(b) }
+
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		switch typ {
+		case wkbMultiPoint:
+			name = "MULTIPOINT"
+		case wkbMultiLineString:
+			name = "MULTILINESTRING"
+		case wkbMultiPolygon:
+			name = "MULTIPOLYGON"
+		default:
+			name = "GEOMETRYCOLLECTION"
+		}
+		body = func() error {
+			n, err := r.readCount()
+			if err != nil {
+				return err
+			}
+			if n == 0 && typ == wkbGeometryCollection {
+				b.WriteString(" EMPTY")
+				return nil
+			}
+			b.WriteByte('(')
+			for i := 0; i < n; i++ {
+				if i != 0 {
+					b.WriteByte(',')
+				}
+				// Elements of collection have names.
+				if err := r.readGeometry(b, typ == wkbGeometryCollection); err != nil {
+					return err
+				}
+			}
+			b.WriteByte(')')
+			return nil
+		}
+
+	default:
+		return fmt.Errorf("Geometry.WKT: unknown geometry type %d", typ)
+	}
+
+	if withName {
+		b.WriteString(name)
+	}
+	return body()
+}
@@ -0,0 +1,67 @@
+package mycanal
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeometry(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, testCase := range []struct {
+		WKB    string
+		Expect string
+	}{
+		{
+			"0101000000000000000000f03f0000000000000040",
+			"POINT(1 2)",
+		},
+		{
+			"01020000000200000000000000000000000000000000000000000000000000f83f000000000000f0bf",
+			"LINESTRING(0 0,1.5 -1)",
+		},
+		{
+			"0103000000010000000400000000000000000000000000000000000000000000000000244000000000000000000000000000002440000000000000244000000000000000000000000000000000",
+			"POLYGON((0 0,10 0,10 10,0 0))",
+		},
+		{
+			// Mixed byte order.
+			"01040000000200000001010000000000000000000000000000000000000000000000013ff00000000000003ff0000000000000",
+			"MULTIPOINT((0 0),(1 1))",
+		},
+		{
+			"0107000000020000000101000000000000000000f03f000000000000004001020000000200000000000000000000000000000000000000000000000000f83f000000000000f0bf",
+			"GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(0 0,1.5 -1))",
+		},
+		{
+			"010700000000000000",
+			"GEOMETRYCOLLECTION EMPTY",
+		},
+	} {
+		wkb, err := hex.DecodeString(testCase.WKB)
+		assert.NoError(err)
+
+		// SRID 4326 in little endian.
+		g, err := ParseGeometry(append([]byte{0xe6, 0x10, 0, 0}, wkb...))
+		assert.NoError(err)
+		assert.Equal(uint32(4326), g.SRID)
+		assert.Equal(wkb, g.WKB)
+		assert.Equal(append([]byte{0xe6, 0x10, 0, 0}, wkb...), g.Bytes())
+
+		wkt, err := g.WKT()
+		assert.NoError(err)
+		assert.Equal(testCase.Expect, wkt)
+		assert.Equal("SRID=4326;"+testCase.Expect, g.String())
+
+		// Truncated.
+		_, err = Geometry{WKB: wkb[:len(wkb)-1]}.WKT()
+		assert.Error(err)
+	}
+
+	_, err := ParseGeometry([]byte{1, 2})
+	assert.Error(err)
+
+}
@@ -0,0 +1,71 @@
+package incrdump
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/shopspring/decimal"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+const (
+	// Collation id of binary charset.
+	binaryCollation = 63
+)
+
+// decimalString returns the string of a DECIMAL value scaled to the declared scale.
+func (meta *tableMeta) decimalString(i int, v decimal.Decimal) string {
+	// NOTE: Meta of NEWDECIMAL is (precision << 8) | scale.
+	return v.StringFixed(int32(meta.ColumnMeta[i] & 0xff))
+}
+
+// bitLength returns the declared byte length of a BIT column.
+func (meta *tableMeta) bitLength(i int) int {
+	// NOTE: Meta of BIT is (bytes << 8) | bits.
+	m := meta.ColumnMeta[i]
+	nbits := int(m>>8)*8 + int(m&0xff)
+	return (nbits + 7) / 8
+}
+
+// binaryLength returns the declared length of a BINARY column or -1 if the column is not BINARY.
+func (meta *tableMeta) binaryLength(i int) int {
+	if meta.RealType(i) != mysql.MYSQL_TYPE_STRING || meta.CollationMap()[i] != binaryCollation {
+		return -1
+	}
+
+	// Copy from go-mysql/replication/row_event.go RowsEvent.decodeValue
+	m := meta.ColumnMeta[i]
+	if m < 256 {
+		return int(m)
+	}
+	b0 := uint8(m >> 8)
+	b1 := uint8(m & 0xff)
+	if b0&0x30 != 0x30 {
+		return int(uint16(b1) | (uint16((b0&0x30)^0x30) << 4))
+	}
+	return int(b1)
+}
+
+// canonicalBinary pads a BINARY value to the declared length.
+func (meta *tableMeta) canonicalBinary(i int, v string) string {
+	length := meta.binaryLength(i)
+	if length < 0 || len(v) >= length {
+		return v
+	}
+	return v + strings.Repeat("\x00", length-len(v))
+}
+
+// canonicalJSON re-serializes a JSON value.
+func (meta *tableMeta) canonicalJSON(i int, v string) string {
+	// NOTE: Empty for NULL JSON in NOT NULL column, see go-mysql's decodeJsonBinary.
+	if v == "" {
+		return v
+	}
+	ret, err := CanonicalJSON(v)
+	if err != nil {
+		panic(fmt.Errorf("Canonical json error for column %d: %s", i, err))
+	}
+	return ret
+}
@@ -0,0 +1,16 @@
+package incrdump
+
+import (
+	"fmt"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+// geometryValue converts a GEOMETRY value in MySQL's internal format to Geometry.
+func (meta *tableMeta) geometryValue(i int, v []byte) Geometry {
+	g, err := ParseGeometry(v)
+	if err != nil {
+		panic(fmt.Errorf("Parse geometry error for column %d: %s", i, err))
+	}
+	return g
+}
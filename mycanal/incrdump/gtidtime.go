@@ -0,0 +1,265 @@
+package incrdump
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pkg/errors"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+var (
+	// ErrTimeBeforeBinlogs is returned by GTIDSetAt when t is before the earliest binlog file on the server,
+	// e.g. binlogs have been purged.
+	ErrTimeBeforeBinlogs = errors.New("incrdump: time is before the earliest binlog")
+)
+
+// binlogFile is a row of SHOW BINARY LOGS.
+type binlogFile struct {
+	Name string
+	Size uint32
+}
+
+// binlogHead is the beginning of a binlog file.
+type binlogHead struct {
+	// Time is the creation time of the binlog file.
+	Time time.Time
+
+	// PrevGTIDSet is the gtid set executed before the binlog file.
+	PrevGTIDSet string
+}
+
+// GTIDSetAt returns the gtid set executed as of t by scanning binlog files on the server: it binary searches
+// the file list (SHOW BINARY LOGS) by their creation time to locate the file containing t, then starts
+// from the Previous_gtids of the file and adds all trxs in it committed before or at t (by
+// ImmediateCommitTimestamp of gtid events, in microseconds).
+//
+// Commit timestamps are normally in binlog order, then the result is the same as the gtid set handled by
+// IncrDumpOpts with StopAtTime t. But they may be out of order (e.g. the system clock is adjusted).
+// In that case all trxs in files before the located one are included and none in files after, while in the
+// located file every trx committed before or at t is included even if it follows one committed after t
+// (the result may have holes), unlike StopAtTime which stops at the first trx committed after t.
+//
+// NOTE: Each file probed (about log2(number of files) + 1) opens a new replication session, since
+// a binlog syncer can't be restarted at another position.
+//
+// A server id is allocated (see AllocServerId) if cfg.ServerId is 0.
+func GTIDSetAt(ctx context.Context, cfg *Config, t time.Time) (string, error) {
+
+	if cfg.ServerId == 0 {
+		serverId, err := AllocServerId(ctx, cfg, nil)
+		if err != nil {
+			return "", errors.WithMessage(err, "incrdump.GTIDSetAt")
+		}
+		c := *cfg
+		c.ServerId = serverId
+		cfg = &c
+	}
+
+	files, err := binlogFiles(ctx, cfg)
+	if err != nil {
+		return "", errors.WithMessage(err, "incrdump.GTIDSetAt")
+	}
+
+	heads := map[int]*binlogHead{}
+	head := func(i int) (*binlogHead, error) {
+		if heads[i] == nil {
+			head, err := readBinlogHead(ctx, cfg, files[i])
+			if err != nil {
+				return nil, err
+			}
+			heads[i] = head
+		}
+		return heads[i], nil
+	}
+
+	// Find the first file created after t, the previous one contains t.
+	i := sort.Search(len(files), func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var h *binlogHead
+		if h, err = head(i); err != nil {
+			return true
+		}
+		return h.Time.After(t)
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "incrdump.GTIDSetAt")
+	}
+	if i == 0 {
+		return "", ErrTimeBeforeBinlogs
+	}
+	last := i - 1
+
+	// Creation time is in seconds, a file created in the same second of t may be created after t,
+	// so start from the previous file as well.
+	first := last
+	for first > 0 {
+		h, err := head(first)
+		if err != nil {
+			return "", errors.WithMessage(err, "incrdump.GTIDSetAt")
+		}
+		if !h.Time.Add(time.Second).After(t) {
+			break
+		}
+		first--
+	}
+
+	h, err := head(first)
+	if err != nil {
+		return "", errors.WithMessage(err, "incrdump.GTIDSetAt")
+	}
+	gset, err := mysql.ParseMysqlGTIDSet(h.PrevGTIDSet)
+	if err != nil {
+		return "", errors.WithMessage(err, "incrdump.GTIDSetAt parse Previous_gtids error")
+	}
+
+	// Scan whole files since commit timestamps may not be strictly in binlog order.
+	for _, file := range files[first : last+1] {
+		err = scanBinlog(ctx, cfg, file, func(binlogEvent *replication.BinlogEvent) (bool, error) {
+			event, ok := binlogEvent.Event.(*replication.GTIDEvent)
+			if !ok || commitTime(binlogEvent.Header, event).After(t) {
+				return true, nil
+			}
+			return true, gset.Update(gtidFromGTIDEvent(event))
+		})
+		if err != nil {
+			return "", errors.WithMessage(err, "incrdump.GTIDSetAt")
+		}
+	}
+	return gset.String(), nil
+}
+
+// binlogFiles returns binlog files on the server.
+func binlogFiles(ctx context.Context, cfg *Config) ([]binlogFile, error) {
+	db, err := cfg.Client()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SHOW BINARY LOGS")
+	if err != nil {
+		return nil, errors.WithMessage(err, "show binary logs error")
+	}
+	defer rows.Close()
+
+	// Columns vary between versions (e.g. 'Encrypted' since MySQL-8.0.14).
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, errors.WithMessage(err, "show binary logs error")
+	}
+	values := make([]sql.NullString, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	ret := []binlogFile{}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.WithMessage(err, "show binary logs error")
+		}
+		file := binlogFile{}
+		for i, name := range names {
+			switch {
+			case strings.EqualFold(name, "Log_name"):
+				file.Name = values[i].String
+			case strings.EqualFold(name, "File_size"):
+				size, err := strconv.ParseUint(values[i].String, 10, 32)
+				if err != nil {
+					return nil, errors.WithMessage(err, "show binary logs error")
+				}
+				file.Size = uint32(size)
+			}
+		}
+		ret = append(ret, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithMessage(err, "show binary logs error")
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("show binary logs error: no binlog, pls make sure log_bin is enabled")
+	}
+	return ret, nil
+}
+
+// readBinlogHead reads the format description event and previous gtids event of a binlog file.
+func readBinlogHead(ctx context.Context, cfg *Config, file binlogFile) (*binlogHead, error) {
+	head := &binlogHead{}
+	err := scanBinlog(ctx, cfg, file, func(binlogEvent *replication.BinlogEvent) (bool, error) {
+		switch event := binlogEvent.Event.(type) {
+		case *replication.FormatDescriptionEvent:
+			head.Time = time.Unix(int64(binlogEvent.Header.Timestamp), 0)
+			return true, nil
+		case *replication.PreviousGTIDsEvent:
+			head.PrevGTIDSet = event.GTIDSets
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if head.Time.IsZero() {
+		return nil, errors.Errorf("binlog %s has no format description event", file.Name)
+	}
+	return head, nil
+}
+
+// scanBinlog calls fn on events of the binlog file until fn returns false or error, or the end of
+// the file (as of file.Size) is reached.
+func scanBinlog(ctx context.Context, cfg *Config, file binlogFile, fn func(*replication.BinlogEvent) (bool, error)) error {
+	syncer, err := newSyncer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(mysql.Position{Name: file.Name, Pos: 4})
+	if err != nil {
+		return errors.WithMessagef(err, "start sync binlog %s error", file.Name)
+	}
+
+	// The first event is a fake rotate event, a rotate event after format description event
+	// means the end of the file.
+	started := false
+	for {
+		binlogEvent, err := streamer.GetEvent(ctx)
+		if err != nil {
+			if err == ctx.Err() {
+				return err
+			}
+			return errors.WithMessagef(err, "read binlog %s error", file.Name)
+		}
+
+		switch binlogEvent.Event.(type) {
+		case *replication.FormatDescriptionEvent:
+			started = true
+		case *replication.RotateEvent:
+			if started {
+				return nil
+			}
+			continue
+		}
+		if binlogEvent.Header.EventType == replication.HEARTBEAT_EVENT {
+			continue
+		}
+
+		cont, err := fn(binlogEvent)
+		if err != nil || !cont {
+			return err
+		}
+		if binlogEvent.Header.LogPos >= file.Size {
+			return nil
+		}
+	}
+}
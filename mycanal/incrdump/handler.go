@@ -14,7 +14,7 @@ import (
 //   - *RowUpdating: row update, between TrxBeginning/TrxEnding
 //   - *RowDeletion: row delete, between TrxBeginning/TrxEnding
 //
-// Maybe more events will be added in the future
+// Maybe more events will be added in the future. See Router for per-table routing and Middleware.
 type Handler func(ctx context.Context, e interface{}) error
 
 // TrxBeginning represents the start of a trx.
@@ -113,9 +113,9 @@ func (e *rowChange) TableName() string {
 	return e.meta.TableName()
 }
 
-// ColumnNames returns column names of the table.
+// ColumnNames returns column names of the table (columns dropped by column rules are not included).
 func (e *rowChange) ColumnNames() []string {
-	return e.meta.ColumnNameString()
+	return e.meta.columnNames()
 }
 
 // BeforeData returns column data before the change or nil if not applicable.
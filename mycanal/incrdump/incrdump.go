@@ -3,6 +3,7 @@ package incrdump
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
@@ -11,27 +12,69 @@ import (
 	. "github.com/huangjunwen/golibs/mycanal"
 )
 
-// IncrDump reads events from mysql binlog, see mycanal's doc for prerequisites
+var (
+	// ErrStopped is returned by IncrDumpOpts when a stop condition (see Options) is reached.
+	ErrStopped = errors.New("incrdump: stop condition reached")
+)
+
+// IncrDump is equivalent to IncrDumpOpts() with opts == nil.
 func IncrDump(
 	ctx context.Context,
 	cfg *Config,
 	gtidSet string,
 	handler Handler,
 ) error {
+	return IncrDumpOpts(ctx, cfg, gtidSet, nil, handler)
+}
+
+// IncrDumpOpts reads events from mysql binlog, see mycanal's doc for prerequisites.
+// It returns nil when ctx is done, or ErrStopped when a stop condition in opts is reached.
+func IncrDumpOpts(
+	ctx context.Context,
+	cfg *Config,
+	gtidSet string,
+	opts *Options,
+	handler Handler,
+) error {
+
+	if cfg.ServerId == 0 && opts.serverId() != nil {
+		serverId, err := AllocServerId(ctx, cfg, opts.serverId())
+		if err != nil {
+			return errors.WithMessage(err, "incrdump.IncrDump")
+		}
+		opts.logger().Info("incrdump server id allocated", "serverId", serverId)
+		if onServerId := opts.onServerId(); onServerId != nil {
+			onServerId(serverId)
+		}
+
+		c := *cfg
+		c.ServerId = serverId
+		cfg = &c
+	}
 
-	conf := cfg.ToBinlogSyncerCfg()
 	gset, err := mysql.ParseMysqlGTIDSet(gtidSet)
 	if err != nil {
 		panic(err)
 	}
 
-	syncer := replication.NewBinlogSyncer(conf)
-	defer syncer.Close()
+	var stopGset mysql.GTIDSet
+	if opts.stopAtGTIDSet() != "" {
+		stopGset, err = mysql.ParseMysqlGTIDSet(opts.stopAtGTIDSet())
+		if err != nil {
+			return errors.WithMessage(err, "incrdump.IncrDump parse StopAtGTIDSet error")
+		}
+		if gset.Contain(stopGset) {
+			return ErrStopped
+		}
+	}
 
-	streamer, err := syncer.StartSyncGTID(gset)
+	syncer, streamer, err := startSync(ctx, cfg, gset)
 	if err != nil {
 		return errors.WithMessage(err, "incrdump.IncrDump start sync gtid error")
 	}
+	defer func() {
+		syncer.Close()
+	}()
 
 	var (
 		prevGset = gset.Clone()
@@ -41,6 +84,19 @@ func IncrDump(
 
 		// Remain size of current trx.
 		trxRemainSize uint64
+
+		// Number of events processed in current trx (after the gtid event).
+		trxEvents int
+
+		// After reconnecting in the middle of a trx, events of the trx are resent from the
+		// beginning, skip events before the gtid event of the trx and the ones already processed.
+		skipToGTID string
+		skipEvents int
+
+		reconnectAttempts int
+
+		// Number of trxs handled.
+		trxs int
 	)
 
 	for {
@@ -53,9 +109,53 @@ func IncrDump(
 		binlogEvent, err := streamer.GetEvent(ctx)
 		if err != nil {
 			if err == ctx.Err() {
-				err = nil
+				return nil
+			}
+
+			// Reconnect (with password refreshed) if PasswordProvider is used, see startSync. Only connection
+			// errors (including read timeouts, which go-mysql wraps as ErrBadConn) are retried, errors
+			// returned by the server are not. See Config.PasswordProvider.
+			if cfg.PasswordProvider == nil || errors.Cause(err) != mysql.ErrBadConn {
+				return errors.WithMessage(err, "incrdump.IncrDump get event error")
+			}
+			syncer.Close()
+			for {
+				reconnectAttempts++
+				if cfg.MaxReconnectAttempts > 0 && reconnectAttempts > cfg.MaxReconnectAttempts {
+					return errors.WithMessagef(err, "incrdump.IncrDump exceeded max reconnect attempts (%d)", cfg.MaxReconnectAttempts)
+				}
+				opts.logger().Error(err, "incrdump reconnect", "attempt", reconnectAttempts)
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Second):
+				}
+
+				newSyncer, newStreamer, startErr := startSync(ctx, cfg, prevGset.Clone())
+				if startErr == nil {
+					syncer, streamer = newSyncer, newStreamer
+					break
+				}
+				err = startErr
+			}
+			if trxCtx != nil {
+				skipToGTID = trxCtx.gtid
+				skipEvents = trxEvents
+			}
+			continue
+		}
+		reconnectAttempts = 0
+
+		if skipToGTID != "" {
+			if event, ok := binlogEvent.Event.(*replication.GTIDEvent); ok && gtidFromGTIDEvent(event) == skipToGTID {
+				skipToGTID = ""
 			}
-			return errors.WithMessage(err, "incrdump.IncrDump get event error")
+			continue
+		}
+		if skipEvents > 0 && binlogEvent.Header.EventType != replication.HEARTBEAT_EVENT {
+			skipEvents--
+			continue
 		}
 
 		// Every trx starts with a gtid event.
@@ -76,12 +176,18 @@ func IncrDump(
 				))
 			}
 
+			// Stop before the first trx committed after StopAtTime.
+			if stopAt := opts.stopAtTime(); !stopAt.IsZero() && commitTime(binlogEvent.Header, event).After(stopAt) {
+				return ErrStopped
+			}
+
 			trxCtx = &TrxContext{
 				prevGset:  prevGset.Clone(),
 				gtidEvent: event,
 				gtid:      gtidFromGTIDEvent(event),
 			}
 			trxRemainSize = safeUint64Minus(event.TransactionLength, uint64(binlogEvent.Header.EventSize))
+			trxEvents = 0
 
 			if err := handler(ctx, (*TrxBeginning)(trxCtx)); err != nil {
 				return err
@@ -93,6 +199,9 @@ func IncrDump(
 		if trxCtx == nil {
 			continue
 		}
+		if binlogEvent.Header.EventType != replication.HEARTBEAT_EVENT {
+			trxEvents++
+		}
 
 		switch event := binlogEvent.Event.(type) {
 
@@ -106,7 +215,12 @@ func IncrDump(
 			}
 
 			// NOTE: We have checked ColumnName above, thus --binlog-row-metadata=FULL should have been enabled.
-			meta := newTableMeta(table)
+			meta := newTableMeta(table, opts)
+			if meta.hasTime2() {
+				if err := meta.decodeTime2Columns(event, binlogEvent.RawData); err != nil {
+					return errors.WithMessage(err, "incrdump.IncrDump")
+				}
+			}
 
 			switch binlogEvent.Header.EventType {
 			case replication.WRITE_ROWS_EVENTv2:
@@ -175,7 +289,44 @@ func IncrDump(
 
 		prevGset = trxCtx.AfterGTIDSet().Clone()
 		trxCtx = nil
+		trxs++
 
+		if stopGset != nil && prevGset.Contain(stopGset) {
+			return ErrStopped
+		}
+		if n := opts.stopAfterTrxs(); n > 0 && trxs >= n {
+			return ErrStopped
+		}
 	}
 
 }
+
+// startSync starts a binlog syncer streaming from gset. If cfg.PasswordProvider is used, the password
+// is got from it and reconnection is handled by IncrDumpOpts instead of go-mysql, which always
+// reconnects with the initial password.
+func startSync(ctx context.Context, cfg *Config, gset mysql.GTIDSet) (*replication.BinlogSyncer, *replication.BinlogStreamer, error) {
+	syncer, err := newSyncer(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamer, err := syncer.StartSyncGTID(gset)
+	if err != nil {
+		syncer.Close()
+		return nil, nil, err
+	}
+	return syncer, streamer, nil
+}
+
+// newSyncer creates a binlog syncer, see startSync.
+func newSyncer(ctx context.Context, cfg *Config) (*replication.BinlogSyncer, error) {
+	conf := cfg.ToBinlogSyncerCfg()
+	if cfg.PasswordProvider != nil {
+		password, err := cfg.PasswordProvider.Password(ctx)
+		if err != nil {
+			return nil, errors.WithMessage(err, "get password error")
+		}
+		conf.Password = password
+		conf.DisableRetrySync = true
+	}
+	return replication.NewBinlogSyncer(conf), nil
+}
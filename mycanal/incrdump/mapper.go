@@ -0,0 +1,47 @@
+package incrdump
+
+import (
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+// valueKind returns the kind of values of column i for ValueMapper.
+func (meta *tableMeta) valueKind(i int) ValueKind {
+	switch meta.RealType(i) {
+	case mysql.MYSQL_TYPE_NEWDECIMAL:
+		return DecimalValue
+	case mysql.MYSQL_TYPE_JSON:
+		return JSONValue
+	case mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_DATETIME2:
+		return DatetimeValue
+	case mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_TIMESTAMP2:
+		return TimestampValue
+	case mysql.MYSQL_TYPE_BIT:
+		return BitValue
+	default:
+		return OtherValue
+	}
+}
+
+// mapValues maps normalized row data by ValueMapper.
+func (meta *tableMeta) mapValues(data []interface{}) {
+	if meta.valueMapper == nil {
+		return
+	}
+
+	names := meta.ColumnNameString()
+	for i, val := range data {
+		kind := meta.valueKind(i)
+		if kind == OtherValue || val == nil {
+			continue
+		}
+		v, err := meta.valueMapper.ForColumn(meta.SchemaName(), meta.TableName(), names[i]).Map(kind, val)
+		if err != nil {
+			panic(fmt.Errorf("Map value error for column %q: %s", names[i], err))
+		}
+		data[i] = v
+	}
+}
@@ -9,27 +9,40 @@ import (
 	. "github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/shopspring/decimal"
+
+	"github.com/huangjunwen/golibs/mycanal"
 )
 
 var (
 	emptyUnsignedMap     = map[int]bool{}
+	emptyCollationMap    = map[int]uint64{}
 	emptyEnumStrValueMap = map[int][]string{}
 	emptySetStrValueMap  = map[int][]string{}
 )
 
 type tableMeta struct {
 	*replication.TableMapEvent
+	canonical    bool
+	timeAsString bool
+	valueMapper  *mycanal.ValueMapper
+	rules        *mycanal.TableRules
 	// cache fields
 	schemaName      string
 	tableName       string
 	unsignedMap     map[int]bool
+	collationMap    map[int]uint64
 	enumStrValueMap map[int][]string
 	setStrValueMap  map[int][]string
+	keptColumnNames []string
 }
 
-func newTableMeta(table *replication.TableMapEvent) *tableMeta {
+func newTableMeta(table *replication.TableMapEvent, opts *Options) *tableMeta {
 	return &tableMeta{
 		TableMapEvent: table,
+		canonical:     opts.canonical(),
+		timeAsString:  opts.timeAsString(),
+		valueMapper:   opts.valueMapper(),
+		rules:         opts.columnRules().ForTable(string(table.Schema), string(table.Table)),
 	}
 }
 
@@ -57,6 +70,16 @@ func (meta *tableMeta) UnsignedMap() map[int]bool {
 	return meta.unsignedMap
 }
 
+func (meta *tableMeta) CollationMap() map[int]uint64 {
+	if meta.collationMap == nil {
+		meta.collationMap = meta.TableMapEvent.CollationMap()
+		if meta.collationMap == nil {
+			meta.collationMap = emptyCollationMap
+		}
+	}
+	return meta.collationMap
+}
+
 func (meta *tableMeta) EnumStrValueMap() map[int][]string {
 	if meta.enumStrValueMap == nil {
 		meta.enumStrValueMap = meta.TableMapEvent.EnumStrValueMap()
@@ -110,7 +133,11 @@ func (meta *tableMeta) NormalizeRowData(data []interface{}) []interface{} {
 		// information is presents in binlog. So we need to convert here if it is unsigned.
 		if meta.IsNumericColumn(i) {
 			if v, ok := val.(decimal.Decimal); ok {
-				data[i] = v.String()
+				if meta.canonical {
+					data[i] = meta.decimalString(i, v)
+				} else {
+					data[i] = v.String()
+				}
 				continue
 			}
 
@@ -203,9 +230,38 @@ func (meta *tableMeta) NormalizeRowData(data []interface{}) []interface{} {
 			if err != nil {
 				panic(err)
 			}
-			data[i] = r.String()
+			if meta.canonical {
+				data[i] = r.String()[8-meta.bitLength(i):]
+			} else {
+				data[i] = r.String()
+			}
 			continue
 
+		case MYSQL_TYPE_TIME2:
+			// NOTE: Decoded by decodeTime2Columns.
+			if v, ok := val.(time.Duration); ok {
+				data[i] = meta.timeValue(i, v)
+				continue
+			}
+
+		case MYSQL_TYPE_GEOMETRY:
+			if v, ok := val.([]byte); ok {
+				data[i] = meta.geometryValue(i, v)
+				continue
+			}
+
+		case MYSQL_TYPE_STRING:
+			if v, ok := val.(string); ok && meta.canonical {
+				data[i] = meta.canonicalBinary(i, v)
+				continue
+			}
+
+		case MYSQL_TYPE_JSON:
+			if v, ok := val.([]byte); ok && meta.canonical {
+				data[i] = meta.canonicalJSON(i, string(v))
+				continue
+			}
+
 		}
 
 		switch v := val.(type) {
@@ -217,5 +273,6 @@ func (meta *tableMeta) NormalizeRowData(data []interface{}) []interface{} {
 		}
 	}
 
-	return data
+	meta.mapValues(data)
+	return meta.applyRules(data)
 }
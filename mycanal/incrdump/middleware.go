@@ -0,0 +1,96 @@
+package incrdump
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/logr"
+)
+
+// Middleware wraps a Handler.
+type Middleware func(Handler) Handler
+
+// Chain applies middlewares to handler, the first middleware is the outermost one.
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Logging logs every event and error returned by the handler.
+func Logging(logger logr.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e interface{}) error {
+			kvs := eventKeysAndValues(e)
+			logger.Info("incrdump event", kvs...)
+			err := next(ctx, e)
+			if err != nil {
+				logger.Error(err, "incrdump handler error", kvs...)
+			}
+			return err
+		}
+	}
+}
+
+// Recovery converts panics in the handler to errors.
+func Recovery() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e interface{}) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					if e, ok := r.(error); ok {
+						err = errors.WithMessage(e, "incrdump handler panic")
+					} else {
+						err = errors.Errorf("incrdump handler panic: %v", r)
+					}
+				}
+			}()
+			return next(ctx, e)
+		}
+	}
+}
+
+// Timing calls fn with the duration of each handler call.
+func Timing(fn func(e interface{}, d time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e interface{}) error {
+			start := time.Now()
+			err := next(ctx, e)
+			fn(e, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Filter drops row changes for which pred returns false. Other events are always passed.
+func Filter(pred func(e RowChange) bool) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, e interface{}) error {
+			if rc, ok := e.(RowChange); ok && !pred(rc) {
+				return nil
+			}
+			return next(ctx, e)
+		}
+	}
+}
+
+func eventKeysAndValues(e interface{}) []interface{} {
+	switch ev := e.(type) {
+	case *TrxBeginning:
+		return []interface{}{"event", "trxBeginning", "gtid", ev.TrxContext().GTID()}
+	case *TrxEnding:
+		return []interface{}{"event", "trxEnding", "gtid", ev.TrxContext().GTID()}
+	case RowChange:
+		return []interface{}{
+			"event", fmt.Sprintf("%T", e),
+			"gtid", ev.TrxContext().GTID(),
+			"table", ev.SchemaName() + "." + ev.TableName(),
+		}
+	default:
+		return []interface{}{"event", fmt.Sprintf("%T", e)}
+	}
+}
@@ -0,0 +1,128 @@
+package incrdump
+
+import (
+	"time"
+
+	"github.com/huangjunwen/golibs/logr"
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+var (
+	// DefaultLogger is the default value of Options.Logger.
+	DefaultLogger = logr.Nop
+)
+
+// Options is options used in IncrDumpOpts.
+type Options struct {
+	// Canonical makes values identical to the ones returned by fulldump with canonical mode (except
+	// JSON doubles with integral value, see the note below):
+	//   - DECIMAL values are scaled to the declared scale
+	//   - BINARY values are padded with '\x00' to the declared length
+	//   - BIT values are truncated to the declared length
+	//   - JSON values are re-serialized by CanonicalJSON
+	//
+	// NOTE: go-mysql decodes JSON doubles in binlog to float64 and serializes them by encoding/json,
+	// so a double with integral value (e.g. 3.0 or 1e20) is indistinguishable from an integer and
+	// differs from the one returned by fulldump (e.g. "3" vs "3.0", "100000000000000000000" vs "1e20").
+	// Integers are exact in both (e.g. 9007199254740993).
+	Canonical bool
+
+	// TimeAsString returns TIME values as string (e.g. "-01:02:03.456") instead of time.Duration.
+	TimeAsString bool
+
+	// ValueMapper maps values to other representations, optional.
+	ValueMapper *ValueMapper
+
+	// ColumnRules are applied to row data before the handler sees them, optional.
+	ColumnRules *ColumnRules
+
+	// StopAtGTIDSet, if not empty, stops IncrDumpOpts (returning ErrStopped) once it is contained in
+	// the executed gtid set, i.e. right after the TrxEnding of the trx completing it.
+	StopAtGTIDSet string
+
+	// StopAtTime, if not zero, stops IncrDumpOpts (returning ErrStopped) before the first trx committed
+	// after it (by ImmediateCommitTimestamp of the gtid event, in microseconds), so no TrxBeginning of
+	// that trx is handled.
+	//
+	// NOTE: It waits for a trx after the cutoff, use ctx if the server may have no writes.
+	StopAtTime time.Time
+
+	// StopAfterTrxs, if > 0, stops IncrDumpOpts (returning ErrStopped) after handling the number of trxs.
+	StopAfterTrxs int
+
+	// ServerId, if not nil, is used to allocate a server id (see AllocServerId) when cfg.ServerId is 0.
+	// The allocated id is logged and reported to OnServerId.
+	ServerId *ServerIdOptions
+
+	// OnServerId, if not nil, is called with the allocated server id (see ServerId) before reading binlog.
+	OnServerId func(serverId uint32)
+
+	// Logger for logging.
+	//
+	// Use DefaultLogger if not set.
+	Logger logr.Logger
+}
+
+func (opts *Options) canonical() bool {
+	return opts != nil && opts.Canonical
+}
+
+func (opts *Options) timeAsString() bool {
+	return opts != nil && opts.TimeAsString
+}
+
+func (opts *Options) valueMapper() *ValueMapper {
+	if opts == nil {
+		return nil
+	}
+	return opts.ValueMapper
+}
+
+func (opts *Options) columnRules() *ColumnRules {
+	if opts == nil {
+		return nil
+	}
+	return opts.ColumnRules
+}
+
+func (opts *Options) stopAtGTIDSet() string {
+	if opts == nil {
+		return ""
+	}
+	return opts.StopAtGTIDSet
+}
+
+func (opts *Options) stopAtTime() time.Time {
+	if opts == nil {
+		return time.Time{}
+	}
+	return opts.StopAtTime
+}
+
+func (opts *Options) stopAfterTrxs() int {
+	if opts == nil {
+		return 0
+	}
+	return opts.StopAfterTrxs
+}
+
+func (opts *Options) serverId() *ServerIdOptions {
+	if opts == nil {
+		return nil
+	}
+	return opts.ServerId
+}
+
+func (opts *Options) onServerId() func(uint32) {
+	if opts == nil {
+		return nil
+	}
+	return opts.OnServerId
+}
+
+func (opts *Options) logger() logr.Logger {
+	if opts != nil && opts.Logger != nil {
+		return opts.Logger
+	}
+	return DefaultLogger
+}
@@ -0,0 +1,208 @@
+package incrdump
+
+import (
+	"context"
+	"path"
+)
+
+// EventType is a bit set of row change event types.
+type EventType int
+
+const (
+	// InsertEvent is *RowInsertion.
+	InsertEvent EventType = 1 << iota
+
+	// UpdateEvent is *RowUpdating.
+	UpdateEvent
+
+	// DeleteEvent is *RowDeletion.
+	DeleteEvent
+
+	// AllEvents includes all row change event types.
+	AllEvents = InsertEvent | UpdateEvent | DeleteEvent
+)
+
+// Router dispatches row changes to handlers registered by schema/table patterns and event types.
+// Use Router.Handle as the Handler of IncrDump:
+//
+//   - A row change is dispatched to every matching route in registration order, or the default
+//     handler if no route matches.
+//   - TrxBeginning is dispatched to a route (or the default handler) through its middlewares right
+//     before the first row change in the trx dispatched to it. Note that middlewares only see row
+//     changes after that, so a route still gets TrxBeginning/TrxEnding if its middlewares (e.g. Filter)
+//     drop all row changes of the trx.
+//   - TrxEnding is dispatched to every route that TrxBeginning was dispatched to.
+//   - TrxBeginning/TrxEnding of a trx without any row change dispatched are dispatched to the default
+//     handler.
+//   - Other events are dispatched to the default handler.
+//
+// Router is not safe for concurrent use, which is fine for IncrDump.
+type Router struct {
+	middlewares []Middleware
+	routes      []*route
+	fallback    *route
+	built       bool // whether middlewares are applied to routes
+
+	// Current trx.
+	trx     *TrxBeginning
+	entered []*route
+}
+
+type route struct {
+	pattern     string
+	types       EventType
+	handler     Handler
+	middlewares []Middleware
+
+	// Middlewares applied, see Router.build.
+	wrapped Handler
+}
+
+// NewRouter creates a Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use appends middlewares applied to all routes (including the default handler), no matter they are
+// registered before or after. The first middleware is the outermost one.
+func (r *Router) Use(middlewares ...Middleware) *Router {
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.built = false
+	return r
+}
+
+// Route registers a handler for row changes of types in tables matching pattern ("schema.table" in path.Match
+// syntax, e.g. "db.*"), with optional middlewares applied after the ones in Use.
+func (r *Router) Route(pattern string, types EventType, handler Handler, middlewares ...Middleware) *Router {
+	r.routes = append(r.routes, newRoute(pattern, types, handler, middlewares))
+	r.built = false
+	return r
+}
+
+// Default sets the default handler, with optional middlewares applied after the ones in Use.
+func (r *Router) Default(handler Handler, middlewares ...Middleware) *Router {
+	r.fallback = newRoute("", 0, handler, middlewares)
+	r.built = false
+	return r
+}
+
+// Handle implements Handler.
+func (r *Router) Handle(ctx context.Context, e interface{}) error {
+	if !r.built {
+		r.build()
+	}
+
+	switch ev := e.(type) {
+	case *TrxBeginning:
+		r.trx = ev
+		r.entered = r.entered[:0]
+		return nil
+
+	case *TrxEnding:
+		entered := r.entered
+		trx := r.trx
+		r.trx = nil
+		r.entered = nil
+
+		if len(entered) == 0 {
+			if r.fallback == nil {
+				return nil
+			}
+			if trx != nil {
+				if err := r.fallback.wrapped(ctx, trx); err != nil {
+					return err
+				}
+			}
+			return r.fallback.wrapped(ctx, ev)
+		}
+		for _, rt := range entered {
+			if err := rt.wrapped(ctx, ev); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case RowChange:
+		typ := rowEventType(e)
+		name := ev.SchemaName() + "." + ev.TableName()
+		matched := false
+		for _, rt := range r.routes {
+			if rt.types&typ == 0 {
+				continue
+			}
+			if ok, _ := path.Match(rt.pattern, name); !ok {
+				continue
+			}
+			matched = true
+			if err := r.dispatchRow(ctx, rt, e); err != nil {
+				return err
+			}
+		}
+		if !matched && r.fallback != nil {
+			return r.dispatchRow(ctx, r.fallback, e)
+		}
+		return nil
+
+	default:
+		if r.fallback != nil {
+			return r.fallback.wrapped(ctx, e)
+		}
+		return nil
+	}
+}
+
+// build applies middlewares to routes.
+func (r *Router) build() {
+	routes := r.routes
+	if r.fallback != nil {
+		routes = append(routes[:len(routes):len(routes)], r.fallback)
+	}
+	for _, rt := range routes {
+		all := append(append([]Middleware{}, r.middlewares...), rt.middlewares...)
+		rt.wrapped = Chain(rt.handler, all...)
+	}
+	r.built = true
+}
+
+// dispatchRow dispatches a row change to a route, after dispatching TrxBeginning to it if it's the first
+// one in the trx.
+func (r *Router) dispatchRow(ctx context.Context, rt *route, e interface{}) error {
+	if r.trx != nil && !r.hasEntered(rt) {
+		r.entered = append(r.entered, rt)
+		if err := rt.wrapped(ctx, r.trx); err != nil {
+			return err
+		}
+	}
+	return rt.wrapped(ctx, e)
+}
+
+func (r *Router) hasEntered(rt *route) bool {
+	for _, entered := range r.entered {
+		if entered == rt {
+			return true
+		}
+	}
+	return false
+}
+
+func newRoute(pattern string, types EventType, handler Handler, middlewares []Middleware) *route {
+	return &route{
+		pattern:     pattern,
+		types:       types,
+		handler:     handler,
+		middlewares: middlewares,
+	}
+}
+
+func rowEventType(e interface{}) EventType {
+	switch e.(type) {
+	case *RowInsertion:
+		return InsertEvent
+	case *RowUpdating:
+		return UpdateEvent
+	case *RowDeletion:
+		return DeleteEvent
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,170 @@
+package incrdump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	trx := func(gtid string) *TrxContext {
+		return &TrxContext{gtid: gtid}
+	}
+	row := func(trxCtx *TrxContext, schema, table string) *rowChange {
+		return &rowChange{
+			trxCtx: trxCtx,
+			meta: &tableMeta{
+				TableMapEvent: &replication.TableMapEvent{
+					Schema: []byte(schema),
+					Table:  []byte(table),
+				},
+			},
+		}
+	}
+	describe := func(e interface{}) string {
+		switch ev := e.(type) {
+		case *TrxBeginning:
+			return "begin " + ev.TrxContext().GTID()
+		case *TrxEnding:
+			return "end " + ev.TrxContext().GTID()
+		case *RowInsertion:
+			return "insert " + ev.TableName()
+		case *RowUpdating:
+			return "update " + ev.TableName()
+		case *RowDeletion:
+			return "delete " + ev.TableName()
+		default:
+			return fmt.Sprintf("%T", e)
+		}
+	}
+
+	logs := []string{}
+	recorder := func(name string) Handler {
+		return func(ctx context.Context, e interface{}) error {
+			logs = append(logs, name+": "+describe(e))
+			return nil
+		}
+	}
+	timings := 0
+
+	// Use after Route still applies to all routes.
+	router := NewRouter().
+		Use(Recovery()).
+		Route("db.user*", AllEvents, recorder("user")).
+		Route("db.*", DeleteEvent, recorder("audit")).
+		Route("db.order*", InsertEvent, recorder("order"), Filter(func(e RowChange) bool {
+			return e.TrxContext().GTID() != "g:3" && e.TableName() != "order_draft"
+		})).
+		Default(recorder("default")).
+		Use(Timing(func(e interface{}, d time.Duration, err error) { timings++ }))
+
+	t1 := trx("g:1")
+	t2 := trx("g:2")
+	t3 := trx("g:3")
+	t4 := trx("g:4")
+	for _, e := range []interface{}{
+		(*TrxBeginning)(t1),
+		&RowInsertion{row(t1, "db", "user")},
+		&RowDeletion{row(t1, "db", "user_role")},
+		&RowUpdating{row(t1, "db", "other")},
+		(*TrxEnding)(t1),
+		// No row.
+		(*TrxBeginning)(t2),
+		(*TrxEnding)(t2),
+		// Filtered.
+		(*TrxBeginning)(t3),
+		&RowInsertion{row(t3, "db", "order")},
+		(*TrxEnding)(t3),
+		// Entered before the first row, no matter it passes the filter or not.
+		(*TrxBeginning)(t4),
+		&RowInsertion{row(t4, "db", "order_draft")},
+		&RowInsertion{row(t4, "db", "order")},
+		(*TrxEnding)(t4),
+	} {
+		assert.NoError(router.Handle(ctx, e))
+	}
+
+	assert.Equal([]string{
+		"user: begin g:1",
+		"user: insert user",
+		"user: delete user_role",
+		"audit: begin g:1",
+		"audit: delete user_role",
+		"default: begin g:1",
+		"default: update other",
+		"user: end g:1",
+		"audit: end g:1",
+		"default: end g:1",
+		"default: begin g:2",
+		"default: end g:2",
+		"order: begin g:3",
+		"order: end g:3",
+		"order: begin g:4",
+		"order: insert order",
+		"order: end g:4",
+	}, logs)
+	// Dropped rows are timed as well.
+	assert.Equal(len(logs)+2, timings)
+
+	// TrxBeginning is not nested in the dispatching of the row change.
+	logs = logs[:0]
+	trace := func(next Handler) Handler {
+		return func(ctx context.Context, e interface{}) error {
+			logs = append(logs, "before "+describe(e))
+			err := next(ctx, e)
+			logs = append(logs, "after "+describe(e))
+			return err
+		}
+	}
+	router = NewRouter().Use(trace).Route("db.*", AllEvents, recorder("db"))
+	for _, e := range []interface{}{
+		(*TrxBeginning)(t1),
+		&RowInsertion{row(t1, "db", "user")},
+		(*TrxEnding)(t1),
+	} {
+		assert.NoError(router.Handle(ctx, e))
+	}
+	assert.Equal([]string{
+		"before begin g:1",
+		"db: begin g:1",
+		"after begin g:1",
+		"before insert user",
+		"db: insert user",
+		"after insert user",
+		"before end g:1",
+		"db: end g:1",
+		"after end g:1",
+	}, logs)
+
+	// Recovery.
+	router = NewRouter().Use(Recovery()).Default(func(ctx context.Context, e interface{}) error {
+		panic(errors.New("boom"))
+	})
+	err := router.Handle(ctx, (*TrxBeginning)(t1))
+	assert.NoError(err)
+	err = router.Handle(ctx, &RowInsertion{row(t1, "db", "user")})
+	assert.Error(err)
+	assert.Contains(err.Error(), "boom")
+
+	// Chain order.
+	order := []string{}
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, e interface{}) error {
+				order = append(order, name)
+				return next(ctx, e)
+			}
+		}
+	}
+	assert.NoError(Chain(func(ctx context.Context, e interface{}) error { return nil }, mw("a"), mw("b"))(ctx, nil))
+	assert.Equal([]string{"a", "b"}, order)
+}
@@ -0,0 +1,34 @@
+package incrdump
+
+// applyRules applies column rules to normalized row data, dropped columns are removed.
+func (meta *tableMeta) applyRules(data []interface{}) []interface{} {
+	if meta.rules == nil {
+		return data
+	}
+
+	names := meta.ColumnNameString()
+	ret := data[:0]
+	for i, val := range data {
+		if meta.rules.Drop(names[i]) {
+			continue
+		}
+		ret = append(ret, meta.rules.Apply(names[i], val))
+	}
+	return ret
+}
+
+// columnNames returns column names with dropped columns removed.
+func (meta *tableMeta) columnNames() []string {
+	if meta.rules == nil {
+		return meta.ColumnNameString()
+	}
+	if meta.keptColumnNames == nil {
+		meta.keptColumnNames = []string{}
+		for _, name := range meta.ColumnNameString() {
+			if !meta.rules.Drop(name) {
+				meta.keptColumnNames = append(meta.keptColumnNames, name)
+			}
+		}
+	}
+	return meta.keptColumnNames
+}
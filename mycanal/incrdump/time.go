@@ -0,0 +1,272 @@
+package incrdump
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+const (
+	timefIntOfs int64 = 0x800000
+	timefOfs    int64 = 0x800000000000
+)
+
+var (
+	// Copy from go-mysql/replication/row_event.go
+	compressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+)
+
+// hasTime2 returns true if the table has TIME columns.
+func (meta *tableMeta) hasTime2() bool {
+	for _, tp := range meta.ColumnType {
+		if tp == mysql.MYSQL_TYPE_TIME2 {
+			return true
+		}
+	}
+	return false
+}
+
+// timeValue converts a TIME value to the output form.
+func (meta *tableMeta) timeValue(i int, d time.Duration) interface{} {
+	if meta.timeAsString {
+		return FormatTime(d, int(meta.ColumnMeta[i]))
+	}
+	return d
+}
+
+// decodeTime2Columns re-decodes TIME values (as time.Duration) in event.Rows from the raw event data,
+// since go-mysql's decodeTime2 loses fractional part or even the whole value in some cases.
+//
+// NOTE: rawData is the whole event, v2 rows event of MySQL 8 is assumed (compressed transaction payloads
+// are not supported by go-mysql at all). The re-decoding is checked against go-mysql's result (column count,
+// NULL values and number of row images) and the event size, an error is returned if they don't match.
+func (meta *tableMeta) decodeTime2Columns(event *replication.RowsEvent, rawData []byte) (err error) {
+
+	defer func() {
+		// In case of malformed data.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("decode TIME columns error: %v", r)
+		}
+	}()
+
+	if event.Version != 2 {
+		return fmt.Errorf("decode TIME columns error: unsupported rows event version %d", event.Version)
+	}
+	if event.ColumnCount != uint64(len(meta.ColumnType)) {
+		return fmt.Errorf("decode TIME columns error: column count %d != %d", event.ColumnCount, len(meta.ColumnType))
+	}
+
+	// Header + table id (6) + flags (2) + extra data (2 + len).
+	pos := replication.EventHeaderSize + 6 + 2 + 2 + len(event.ExtraData)
+	pos += lengthEncodedIntSize(event.ColumnCount)
+	pos += len(event.ColumnBitmap1) + len(event.ColumnBitmap2)
+
+	bitmaps := [][]byte{event.ColumnBitmap1}
+	if event.ColumnBitmap2 != nil {
+		bitmaps = append(bitmaps, event.ColumnBitmap2)
+	}
+	for _, bitmap := range bitmaps {
+		if len(bitmap) != (len(meta.ColumnType)+7)/8 {
+			return fmt.Errorf("decode TIME columns error: bad column bitmap size %d", len(bitmap))
+		}
+	}
+
+	for r := 0; r < len(event.Rows); r++ {
+		bitmap := bitmaps[r%len(bitmaps)]
+		n, err := meta.decodeTime2Row(rawData[pos:], bitmap, event.Rows[r])
+		if err != nil {
+			return err
+		}
+		pos += n
+	}
+
+	// All row images should have been consumed, except the checksum (if any).
+	if rest := len(rawData) - pos; rest != 0 && rest != replication.BinlogChecksumLength {
+		return fmt.Errorf("decode TIME columns error: %d bytes remain after %d row images", rest, len(event.Rows))
+	}
+	return nil
+}
+
+// decodeTime2Row decodes TIME values of a row image and returns the size of the row image.
+func (meta *tableMeta) decodeTime2Row(data []byte, bitmap []byte, row []interface{}) (int, error) {
+
+	isBitSet := func(bitmap []byte, i int) bool {
+		return bitmap[i>>3]&(1<<(uint(i)&7)) > 0
+	}
+
+	count := 0
+	for i := 0; i < len(meta.ColumnType); i++ {
+		if isBitSet(bitmap, i) {
+			count++
+		}
+	}
+	nullBitmap := data[:(count+7)/8]
+	pos := len(nullBitmap)
+
+	nullIndex := 0
+	for i, tp := range meta.ColumnType {
+		if !isBitSet(bitmap, i) {
+			continue
+		}
+		isNull := isBitSet(nullBitmap, nullIndex)
+		nullIndex++
+		if isNull != (row[i] == nil) {
+			return 0, fmt.Errorf("decode TIME columns error: NULL mismatch for column %d", i)
+		}
+		if isNull {
+			continue
+		}
+
+		m := meta.ColumnMeta[i]
+		n, err := valueSize(data[pos:], tp, m)
+		if err != nil {
+			return 0, err
+		}
+		if tp == mysql.MYSQL_TYPE_TIME2 {
+			row[i] = decodeTime2(data[pos:pos+n], m)
+		}
+		pos += n
+	}
+	return pos, nil
+}
+
+// decodeTime2 decodes TIME value with fsp dec, see MySQL's my_time_packed_from_binary and TIME_from_longlong_time_packed.
+func decodeTime2(data []byte, dec uint16) time.Duration {
+	var packed int64
+	switch dec {
+	case 0:
+		packed = (int64(mysql.BFixedLengthInt(data[0:3])) - timefIntOfs) << 24
+
+	case 1, 2:
+		intPart := int64(mysql.BFixedLengthInt(data[0:3])) - timefIntOfs
+		frac := int64(data[3])
+		if intPart < 0 && frac > 0 {
+			// Negative values are stored with reverse fractional part order.
+			intPart++
+			frac -= 0x100
+		}
+		packed = intPart<<24 + frac*10000
+
+	case 3, 4:
+		intPart := int64(mysql.BFixedLengthInt(data[0:3])) - timefIntOfs
+		frac := int64(binary.BigEndian.Uint16(data[3:5]))
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		packed = intPart<<24 + frac*100
+
+	default:
+		packed = int64(mysql.BFixedLengthInt(data[0:6])) - timefOfs
+	}
+
+	neg := packed < 0
+	if neg {
+		packed = -packed
+	}
+	hms := packed >> 24
+	hour := (hms >> 12) % (1 << 10)
+	minute := (hms >> 6) % (1 << 6)
+	second := hms % (1 << 6)
+	micro := packed % (1 << 24)
+
+	d := time.Duration(hour)*time.Hour +
+		time.Duration(minute)*time.Minute +
+		time.Duration(second)*time.Second +
+		time.Duration(micro)*time.Microsecond
+	if neg {
+		d = -d
+	}
+	return d
+}
+
+// valueSize returns the size of a column value in rows event.
+// Modified from go-mysql/replication/row_event.go RowsEvent.decodeValue
+func valueSize(data []byte, tp byte, meta uint16) (int, error) {
+	length := 0
+	if tp == mysql.MYSQL_TYPE_STRING {
+		if meta >= 256 {
+			b0 := uint8(meta >> 8)
+			b1 := uint8(meta & 0xFF)
+			if b0&0x30 != 0x30 {
+				length = int(uint16(b1) | (uint16((b0&0x30)^0x30) << 4))
+				tp = b0 | 0x30
+			} else {
+				length = int(meta & 0xFF)
+				tp = b0
+			}
+		} else {
+			length = int(meta)
+		}
+	}
+
+	switch tp {
+	case mysql.MYSQL_TYPE_NULL:
+		return 0, nil
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_YEAR:
+		return 1, nil
+	case mysql.MYSQL_TYPE_SHORT:
+		return 2, nil
+	case mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_DATE:
+		return 3, nil
+	case mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_TIMESTAMP:
+		return 4, nil
+	case mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_DOUBLE, mysql.MYSQL_TYPE_DATETIME:
+		return 8, nil
+	case mysql.MYSQL_TYPE_NEWDECIMAL:
+		return decimalSize(int(meta>>8), int(meta&0xFF)), nil
+	case mysql.MYSQL_TYPE_BIT:
+		nbits := int(meta>>8)*8 + int(meta&0xFF)
+		return (nbits + 7) / 8, nil
+	case mysql.MYSQL_TYPE_TIMESTAMP2:
+		return 4 + int(meta+1)/2, nil
+	case mysql.MYSQL_TYPE_DATETIME2:
+		return 5 + int(meta+1)/2, nil
+	case mysql.MYSQL_TYPE_TIME2:
+		return 3 + int(meta+1)/2, nil
+	case mysql.MYSQL_TYPE_ENUM, mysql.MYSQL_TYPE_SET:
+		return int(meta & 0xFF), nil
+	case mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_GEOMETRY, mysql.MYSQL_TYPE_JSON:
+		return int(meta) + int(mysql.FixedLengthInt(data[:meta])), nil
+	case mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VAR_STRING:
+		return stringSize(data, int(meta)), nil
+	case mysql.MYSQL_TYPE_STRING:
+		return stringSize(data, length), nil
+	default:
+		return 0, fmt.Errorf("unsupport type %d in binlog", tp)
+	}
+}
+
+func stringSize(data []byte, length int) int {
+	if length < 256 {
+		return 1 + int(data[0])
+	}
+	return 2 + int(binary.LittleEndian.Uint16(data))
+}
+
+func decimalSize(precision, decimals int) int {
+	integral := precision - decimals
+	uncompIntegral := integral / 9
+	uncompFractional := decimals / 9
+	compIntegral := integral - uncompIntegral*9
+	compFractional := decimals - uncompFractional*9
+	return uncompIntegral*4 + compressedBytes[compIntegral] + uncompFractional*4 + compressedBytes[compFractional]
+}
+
+func lengthEncodedIntSize(n uint64) int {
+	switch {
+	case n < 251:
+		return 1
+	case n < 1<<16:
+		return 3
+	case n < 1<<24:
+		return 4
+	default:
+		return 9
+	}
+}
@@ -0,0 +1,137 @@
+package incrdump
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeTime2(t *testing.T) {
+
+	assert := assert.New(t)
+
+	hms := func(h, m, s, us int) time.Duration {
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+			time.Duration(s)*time.Second + time.Duration(us)*time.Microsecond
+	}
+
+	for i, testCase := range []struct {
+		Data   []byte
+		Dec    uint16
+		Expect time.Duration
+	}{
+		{[]byte{0x80, 0x00, 0x00}, 0, 0},
+		{[]byte{0xb4, 0x6e, 0xfb}, 0, hms(838, 59, 59, 0)},
+		{[]byte{0x4b, 0x91, 0x05}, 0, -hms(838, 59, 59, 0)},
+		{[]byte{0x80, 0x00, 0x00, 0x32}, 1, hms(0, 0, 0, 500000)},
+		{[]byte{0x7f, 0xff, 0xff, 0xce}, 1, -hms(0, 0, 0, 500000)},
+		{[]byte{0x7f, 0xef, 0x7c, 0xd3}, 2, -hms(1, 2, 3, 450000)},
+		{[]byte{0x7f, 0xff, 0xfe, 0xff, 0xff}, 3, -hms(0, 0, 1, 100)},
+		{[]byte{0x81, 0x45, 0x14, 0x04, 0xd2}, 4, hms(20, 20, 20, 123400)},
+		{[]byte{0x7f, 0xff, 0xff, 0xfe, 0x79, 0x60}, 5, -hms(0, 0, 0, 100000)},
+		{[]byte{0x81, 0x45, 0x14, 0x01, 0xe2, 0x40}, 6, hms(20, 20, 20, 123456)},
+		{[]byte{0x7f, 0xef, 0x7c, 0xff, 0xff, 0xfc}, 6, -hms(1, 2, 3, 4)},
+	} {
+		assert.Equal(testCase.Expect, decodeTime2(testCase.Data, testCase.Dec), "test case %d", i)
+	}
+
+}
+
+func TestDecodeTime2Row(t *testing.T) {
+
+	assert := assert.New(t)
+
+	meta := newTableMeta(&replication.TableMapEvent{
+		ColumnCount: 5,
+		ColumnType: []byte{
+			mysql.MYSQL_TYPE_LONG,
+			mysql.MYSQL_TYPE_VARCHAR,
+			mysql.MYSQL_TYPE_TIME2,
+			mysql.MYSQL_TYPE_NEWDECIMAL,
+			mysql.MYSQL_TYPE_TIME2,
+		},
+		ColumnMeta: []uint16{0, 10, 6, 10<<8 | 2, 1},
+	}, nil)
+
+	// The 4th column (decimal) is NULL.
+	data := []byte{
+		0x08,                   // null bitmap
+		0x01, 0x00, 0x00, 0x00, // int
+		0x03, 'a', 'b', 'c', // varchar
+		0x81, 0x45, 0x14, 0x01, 0xe2, 0x40, // time(6)
+		0x7f, 0xff, 0xff, 0xce, // time(1)
+		0xff, // next row
+	}
+	row := []interface{}{int32(1), "abc", "20:20:20", nil, "00:00:00"}
+
+	n, err := meta.decodeTime2Row(data, []byte{0x1f}, row)
+	assert.NoError(err)
+	assert.Equal(len(data)-1, n)
+	assert.Equal([]interface{}{
+		int32(1),
+		"abc",
+		20*time.Hour + 20*time.Minute + 20*time.Second + 123456*time.Microsecond,
+		nil,
+		-500 * time.Millisecond,
+	}, row)
+
+	// NULL mismatch with go-mysql's result, e.g. misaligned data.
+	row = []interface{}{int32(1), "abc", "20:20:20", "1.00", "00:00:00"}
+	_, err = meta.decodeTime2Row(data, []byte{0x1f}, row)
+	assert.Error(err)
+}
+
+func TestDecodeTime2Columns(t *testing.T) {
+
+	assert := assert.New(t)
+
+	meta := newTableMeta(&replication.TableMapEvent{
+		ColumnCount: 2,
+		ColumnType:  []byte{mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_TIME2},
+		ColumnMeta:  []uint16{0, 0},
+	}, nil)
+
+	header := make([]byte, replication.EventHeaderSize)
+	body := []byte{
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, // table id
+		0x00, 0x00, // flags
+		0x02, 0x00, // extra data length
+		0x02,                   // column count
+		0x03,                   // column bitmap
+		0x00,                   // null bitmap
+		0x01, 0x00, 0x00, 0x00, // int
+		0x80, 0x10, 0x00, // time: 01:00:00
+	}
+	rawData := append(header, body...)
+	newEvent := func() *replication.RowsEvent {
+		return &replication.RowsEvent{
+			Version:       2,
+			ColumnCount:   2,
+			ColumnBitmap1: []byte{0x03},
+			Rows:          [][]interface{}{{int32(1), "01:00:00"}},
+		}
+	}
+
+	// With and without checksum.
+	for _, data := range [][]byte{rawData, append(append([]byte{}, rawData...), 0, 0, 0, 0)} {
+		event := newEvent()
+		assert.NoError(meta.decodeTime2Columns(event, data))
+		assert.Equal(time.Hour, event.Rows[0][1])
+	}
+
+	// Unsupported version.
+	event := newEvent()
+	event.Version = 1
+	assert.Error(meta.decodeTime2Columns(event, rawData))
+
+	// Bytes remain: more row images than go-mysql decoded.
+	event = newEvent()
+	assert.Error(meta.decodeTime2Columns(event, append(append([]byte{}, rawData...), body[12:]...)))
+
+	// Truncated.
+	event = newEvent()
+	assert.Error(meta.decodeTime2Columns(event, rawData[:len(rawData)-2]))
+}
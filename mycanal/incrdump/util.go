@@ -2,6 +2,7 @@ package incrdump
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/replication"
 	uuid "github.com/satori/go.uuid"
@@ -21,3 +22,13 @@ func gtidFromGTIDEvent(e *replication.GTIDEvent) string {
 		e.GNO,
 	)
 }
+
+// commitTime returns the commit time of the trx of a gtid event: ImmediateCommitTimestamp (in microseconds,
+// since MySQL-8.0.1) or the event timestamp (the trx start time in seconds) if not available.
+func commitTime(header *replication.EventHeader, e *replication.GTIDEvent) time.Time {
+	if e.ImmediateCommitTimestamp != 0 {
+		us := int64(e.ImmediateCommitTimestamp)
+		return time.Unix(us/1e6, us%1e6*1e3)
+	}
+	return time.Unix(int64(header.Timestamp), 0)
+}
@@ -0,0 +1,13 @@
+// Package incrsnapshot provides lock free incremental snapshot interleaved with the binlog stream.
+//
+// It implements the watermark based algorithm of DBLog: a table is read in primary key chunks,
+// each chunk is read between a low and a high watermark written to a signal table. Row changes
+// of the table between the two watermarks in binlog are newer than the chunk, so rows with
+// conflicting primary keys are dropped from the chunk, and the remaining rows are emitted at
+// the position of the high watermark. Thus snapshot rows and binlog events form a single
+// ordered stream.
+//
+// ref:
+//   - https://arxiv.org/pdf/2010.12597v1.pdf
+//   - https://debezium.io/blog/2021/10/07/incremental-snapshots/
+package incrsnapshot
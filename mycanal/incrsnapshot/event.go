@@ -0,0 +1,33 @@
+package incrsnapshot
+
+import (
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+// RowSnapshot is a row read by incremental snapshot.
+type RowSnapshot struct {
+	// Table is the table of the row.
+	Table fulldump.TableRef
+
+	// DataMap is the row data (column name -> column data).
+	DataMap map[string]interface{}
+}
+
+// ChunkEnding is emitted after all rows of a chunk.
+//
+// To resume an incremental snapshot, persist LastKey (along with the GTID set of the current trx)
+// and pass it to Snapshotter.Snapshot after restart.
+type ChunkEnding struct {
+	// Table is the table of the chunk.
+	Table fulldump.TableRef
+
+	// Seq is the sequence number of the chunk in this run, starts from 0.
+	Seq int
+
+	// LastKey is the primary key values of the last row in the chunk, as read from the database
+	// (e.g. TIME values are strings), see fulldump.TableQuery.Paginate.
+	LastKey []interface{}
+
+	// Done is true if this is the last chunk of the table.
+	Done bool
+}
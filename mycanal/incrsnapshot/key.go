@@ -0,0 +1,135 @@
+package incrsnapshot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+// keyEncoder encodes primary key values of rows from both snapshot (fulldump) and binlog (incrdump)
+// into the same string, since the two paths may return different representations of the same value:
+// DATETIME in different locations, DECIMAL with/without trailing zeros, BINARY with/without padding.
+type keyEncoder struct {
+	pk    []string
+	types []string // DATA_TYPE in information_schema
+}
+
+func newKeyEncoder(ctx context.Context, db *sql.DB, table fulldump.TableRef, pk []string) (*keyEncoder, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		"SELECT COLUMN_NAME, DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=?",
+		table.Schema,
+		table.Table,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "incrsnapshot query column types error")
+	}
+	defer rows.Close()
+
+	types := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, errors.WithMessage(err, "incrsnapshot query column types error")
+		}
+		types[name] = strings.ToLower(typ)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithMessage(err, "incrsnapshot query column types error")
+	}
+
+	ret := &keyEncoder{
+		pk:    pk,
+		types: make([]string, len(pk)),
+	}
+	for i, name := range pk {
+		ret.types[i] = types[name]
+	}
+	return ret, nil
+}
+
+// encode returns the canonical encoding of primary key values of data.
+func (enc *keyEncoder) encode(data map[string]interface{}) string {
+	b := strings.Builder{}
+	for i, name := range enc.pk {
+		val := data[name]
+		if val == nil {
+			b.WriteString("-:")
+			continue
+		}
+		part := encodeKeyValue(enc.types[i], val)
+		// Length prefixed so that values containing any byte can be joined.
+		b.WriteString(strconv.Itoa(len(part)))
+		b.WriteByte(':')
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func encodeKeyValue(typ string, val interface{}) string {
+	switch v := val.(type) {
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+
+	case decimal.Decimal:
+		return v.String()
+
+	case time.Time:
+		// DATETIME/DATE values have no time zone, the wall clock is the value.
+		if typ == "timestamp" {
+			v = v.UTC()
+		}
+		return v.Format("2006-01-02 15:04:05.999999999")
+
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10)
+
+	case []byte:
+		return encodeKeyString(typ, string(v))
+
+	case string:
+		return encodeKeyString(typ, v)
+
+	default:
+		return fmt.Sprintf("%T:%v", v, v)
+	}
+}
+
+func encodeKeyString(typ string, s string) string {
+	switch typ {
+	case "decimal":
+		if d, err := decimal.NewFromString(s); err == nil {
+			return d.String()
+		}
+	case "binary":
+		return strings.TrimRight(s, "\x00")
+	}
+	return s
+}
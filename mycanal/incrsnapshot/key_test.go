@@ -0,0 +1,59 @@
+package incrsnapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyEncoder(t *testing.T) {
+	assert := assert.New(t)
+
+	enc := &keyEncoder{
+		pk:    []string{"dt", "d", "b", "ts"},
+		types: []string{"datetime", "decimal", "binary", "timestamp"},
+	}
+	loc := time.FixedZone("X", 8*3600)
+
+	// Snapshot and binlog representations of the same key.
+	snapshot := map[string]interface{}{
+		"dt": time.Date(2021, 1, 2, 3, 4, 5, 600000000, time.UTC),
+		"d":  "1.50",
+		"b":  "ab\x00\x00",
+		"ts": time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	binlog := map[string]interface{}{
+		"dt": time.Date(2021, 1, 2, 3, 4, 5, 600000000, loc),
+		"d":  "1.5",
+		"b":  []byte("ab"),
+		"ts": time.Date(2021, 1, 2, 11, 4, 5, 0, loc),
+	}
+	assert.Equal(enc.encode(snapshot), enc.encode(binlog))
+
+	// Different keys.
+	for name, val := range map[string]interface{}{
+		"dt": time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		"d":  "1.51",
+		"b":  "ab\x00c",
+		"ts": time.Date(2021, 1, 2, 3, 4, 5, 0, loc),
+	} {
+		other := map[string]interface{}{}
+		for k, v := range snapshot {
+			other[k] = v
+		}
+		other[name] = val
+		assert.NotEqual(enc.encode(snapshot), enc.encode(other), name)
+	}
+
+	// Integers of different widths and NULL.
+	enc = &keyEncoder{pk: []string{"a", "b"}, types: []string{"int", "varchar"}}
+	assert.Equal(
+		enc.encode(map[string]interface{}{"a": int32(1), "b": nil}),
+		enc.encode(map[string]interface{}{"a": int64(1), "b": nil}),
+	)
+	assert.NotEqual(
+		enc.encode(map[string]interface{}{"a": int32(1), "b": nil}),
+		enc.encode(map[string]interface{}{"a": int32(1), "b": "\\N"}),
+	)
+}
@@ -0,0 +1,405 @@
+package incrsnapshot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/huangjunwen/golibs/logr"
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+)
+
+var (
+	// DefaultId is the default value of Options.Id.
+	DefaultId = "mycanal"
+
+	// DefaultChunkSize is the default value of Options.ChunkSize.
+	DefaultChunkSize = 1024
+
+	// DefaultLogger is the default value of Options.Logger.
+	DefaultLogger = logr.Nop
+)
+
+// Options is options used in New.
+type Options struct {
+	// SignalTable is the table to write watermarks, it will be created if not exists. Required.
+	SignalTable fulldump.TableRef
+
+	// Id distinguishes different Snapshotters sharing the same signal table.
+	//
+	// Use DefaultId if not set.
+	Id string
+
+	// ChunkSize is the max number of rows in a chunk.
+	//
+	// Use DefaultChunkSize if not set.
+	ChunkSize int
+
+	// Logger for logging.
+	//
+	// Use DefaultLogger if not set.
+	Logger logr.Logger
+}
+
+// Snapshotter runs IncrDump and interleaves incremental snapshots of tables into the event stream.
+type Snapshotter struct {
+	cfg         *Config
+	signalTable fulldump.TableRef
+	id          string
+	chunkSize   int
+	logger      logr.Logger
+
+	mu       sync.Mutex
+	requests []*request
+	notify   chan struct{} // notify new requests
+	window   *window       // current chunk window
+}
+
+type request struct {
+	table fulldump.TableRef
+	after []interface{}
+}
+
+// window is the state of a chunk between low and high watermarks.
+type window struct {
+	id    string
+	table fulldump.TableRef
+	pk    []string
+	key   *keyEncoder
+	seq   int
+
+	// Set when the low watermark is seen in binlog.
+	opened bool
+
+	// Primary keys of row changes seen inside the window.
+	conflicts map[string]struct{}
+
+	// Set before writing the high watermark.
+	rows    []map[string]interface{}
+	lastKey []interface{}
+	done    bool
+
+	// Closed after the high watermark is handled.
+	closed chan struct{}
+}
+
+// New creates a Snapshotter.
+func New(cfg *Config, opts *Options) (*Snapshotter, error) {
+	if opts == nil || opts.SignalTable.Schema == "" || opts.SignalTable.Table == "" {
+		return nil, errors.New("incrsnapshot.New: no SignalTable")
+	}
+
+	s := &Snapshotter{
+		cfg:         cfg,
+		signalTable: opts.SignalTable,
+		id:          DefaultId,
+		chunkSize:   DefaultChunkSize,
+		logger:      DefaultLogger,
+		notify:      make(chan struct{}, 1),
+	}
+	if opts.Id != "" {
+		s.id = opts.Id
+	}
+	if opts.ChunkSize > 0 {
+		s.chunkSize = opts.ChunkSize
+	}
+	if opts.Logger != nil {
+		s.logger = opts.Logger
+	}
+	return s, nil
+}
+
+// Snapshot requests an incremental snapshot of a table, which must have a primary key.
+// It can be called at any time (e.g. when a table is added to a running pipeline).
+// Tables are snapshotted one by one in the order of requests.
+//
+// after is the primary key values (ChunkEnding.LastKey) to resume from, or nil to start
+// from the beginning.
+func (s *Snapshotter) Snapshot(table fulldump.TableRef, after []interface{}) {
+	s.mu.Lock()
+	s.requests = append(s.requests, &request{
+		table: table,
+		after: after,
+	})
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run is similar to incrdump.IncrDump but handler receives extra events from incremental snapshots:
+//
+//   - *RowSnapshot: a row read by incremental snapshot, between TrxBeginning/TrxEnding of the high watermark trx
+//   - *ChunkEnding: the end of a chunk, after its RowSnapshot
+//
+// Row changes of the signal table are not passed to handler.
+func (s *Snapshotter) Run(ctx context.Context, gtidSet string, handler incrdump.Handler) error {
+
+	db, err := s.cfg.Client()
+	if err != nil {
+		return errors.WithMessage(err, "incrsnapshot.Run open client error")
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id VARCHAR(64) PRIMARY KEY, watermark VARCHAR(64) NOT NULL)",
+		s.signalTable.Quoted(),
+	)); err != nil {
+		return errors.WithMessage(err, "incrsnapshot.Run create signal table error")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	snapshotErrCh := make(chan error, 1)
+	go func() {
+		err := s.snapshotLoop(ctx, db)
+		if err != nil {
+			cancel()
+		}
+		snapshotErrCh <- err
+	}()
+
+	err = incrdump.IncrDump(ctx, s.cfg, gtidSet, func(ctx context.Context, e interface{}) error {
+		return s.handle(ctx, e, handler)
+	})
+	cancel()
+
+	if snapshotErr := <-snapshotErrCh; err == nil {
+		err = snapshotErr
+	}
+	return err
+}
+
+func (s *Snapshotter) handle(ctx context.Context, e interface{}, handler incrdump.Handler) error {
+	rowChange, ok := e.(incrdump.RowChange)
+	if !ok {
+		return handler(ctx, e)
+	}
+
+	// Watermarks.
+	if rowChange.SchemaName() == s.signalTable.Schema && rowChange.TableName() == s.signalTable.Table {
+		data := rowChange.AfterDataMap()
+		if data == nil || data["id"] != s.id {
+			return nil
+		}
+		watermark, _ := data["watermark"].(string)
+		return s.handleWatermark(ctx, watermark, handler)
+	}
+
+	// Record conflicts.
+	s.mu.Lock()
+	if w := s.window; w != nil && w.opened &&
+		rowChange.SchemaName() == w.table.Schema && rowChange.TableName() == w.table.Table {
+
+		for _, data := range []map[string]interface{}{
+			rowChange.BeforeDataMap(),
+			rowChange.AfterDataMap(),
+		} {
+			if data != nil {
+				w.conflicts[w.key.encode(data)] = struct{}{}
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	return handler(ctx, e)
+}
+
+func (s *Snapshotter) handleWatermark(ctx context.Context, watermark string, handler incrdump.Handler) error {
+	parts := strings.SplitN(watermark, ":", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+
+	s.mu.Lock()
+	w := s.window
+	if w == nil || w.id != parts[1] {
+		// Stale watermark (e.g. from previous run).
+		s.mu.Unlock()
+		return nil
+	}
+
+	switch parts[0] {
+	case "low":
+		w.opened = true
+		s.mu.Unlock()
+		return nil
+
+	case "high":
+		s.window = nil
+		s.mu.Unlock()
+		defer close(w.closed)
+
+	default:
+		s.mu.Unlock()
+		return nil
+	}
+
+	// Emit chunk rows at the position of the high watermark.
+	for _, row := range w.rows {
+		if _, ok := w.conflicts[w.key.encode(row)]; ok {
+			continue
+		}
+		if err := handler(ctx, &RowSnapshot{
+			Table:   w.table,
+			DataMap: row,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return handler(ctx, &ChunkEnding{
+		Table:   w.table,
+		Seq:     w.seq,
+		LastKey: w.lastKey,
+		Done:    w.done,
+	})
+}
+
+func (s *Snapshotter) snapshotLoop(ctx context.Context, db *sql.DB) error {
+	for {
+		s.mu.Lock()
+		var req *request
+		if len(s.requests) != 0 {
+			req = s.requests[0]
+			s.requests = s.requests[1:]
+		}
+		s.mu.Unlock()
+
+		if req == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-s.notify:
+				continue
+			}
+		}
+
+		if err := s.snapshotTable(ctx, db, req); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (s *Snapshotter) snapshotTable(ctx context.Context, db *sql.DB, req *request) error {
+
+	table := req.table
+	pk, err := fulldump.PrimaryKey(ctx, db, table)
+	if err != nil {
+		return errors.WithMessage(err, "incrsnapshot")
+	}
+	if len(pk) == 0 {
+		return errors.Errorf("incrsnapshot: table %s.%s has no primary key", table.Schema, table.Table)
+	}
+	key, err := newKeyEncoder(ctx, db, table, pk)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("incrsnapshot table begin", "schema", table.Schema, "table", table.Table)
+	lastKey := req.after
+
+	for seq := 0; ; seq++ {
+		w := &window{
+			id:        uuid.NewV4().String(),
+			table:     table,
+			pk:        pk,
+			key:       key,
+			seq:       seq,
+			conflicts: map[string]struct{}{},
+			closed:    make(chan struct{}),
+		}
+		s.mu.Lock()
+		s.window = w
+		s.mu.Unlock()
+
+		if err := s.writeWatermark(ctx, db, "low:"+w.id); err != nil {
+			return err
+		}
+
+		rows, rowsKey, err := s.readChunk(ctx, db, table, lastKey)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		w.rows = rows
+		w.lastKey = lastKey
+		if rowsKey != nil {
+			w.lastKey = rowsKey
+		}
+		w.done = len(rows) < s.chunkSize
+		s.mu.Unlock()
+
+		if err := s.writeWatermark(ctx, db, "high:"+w.id); err != nil {
+			return err
+		}
+
+		// Wait the high watermark handled in binlog stream.
+		select {
+		case <-w.closed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if w.done {
+			s.logger.Info("incrsnapshot table end", "schema", table.Schema, "table", table.Table)
+			return nil
+		}
+		lastKey = w.lastKey
+	}
+}
+
+func (s *Snapshotter) writeWatermark(ctx context.Context, db *sql.DB, watermark string) error {
+	_, err := db.ExecContext(
+		ctx,
+		fmt.Sprintf("REPLACE INTO %s (id, watermark) VALUES (?, ?)", s.signalTable.Quoted()),
+		s.id,
+		watermark,
+	)
+	return errors.WithMessage(err, "incrsnapshot write watermark error")
+}
+
+// readChunk reads at most chunkSize rows after the key, it also returns the key of the last row read
+// (nil if no row), which is read from the database instead of taken from the converted rows.
+func (s *Snapshotter) readChunk(ctx context.Context, db *sql.DB, table fulldump.TableRef, after []interface{}) ([]map[string]interface{}, []interface{}, error) {
+
+	// NOTE: Only one page is read since at most chunkSize rows are read.
+	iter, lastKey, err := (&fulldump.TableQuery{
+		Table: table,
+		After: after,
+	}).Paginate(ctx, db, s.chunkSize)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "incrsnapshot read chunk error")
+	}
+	defer iter(false)
+
+	rows := []map[string]interface{}{}
+	for len(rows) < s.chunkSize {
+		row, err := iter(true)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "incrsnapshot read chunk error")
+		}
+		if row == nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return rows, nil, nil
+	}
+	return rows, lastKey(), nil
+}
@@ -0,0 +1,179 @@
+package mycanal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CanonicalJSON re-serializes a JSON text in MySQL's canonical form (the form MySQL outputs JSON values):
+//   - object keys are sorted by length and then bytewise
+//   - ", " and ": " are used as separators
+//   - only '"', '\\' and control characters are escaped in strings
+//
+// Integer literals fitting in int64/uint64 are kept as is. Other numbers (with fraction or exponent, or
+// integers out of range) are doubles in MySQL and are normalized the way MySQL outputs them (e.g.
+// "1.0E2" -> "100.0", "1e+20" -> "1e20", "18446744073709551616" -> "1.8446744073709552e19").
+func CanonicalJSON(text string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	if dec.More() {
+		return "", fmt.Errorf("CanonicalJSON: unexpected data after top-level value")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeCanonicalJSON(buf, v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+
+	case json.Number:
+		num, err := canonicalJSONNumber(val)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(num)
+
+	case string:
+		writeCanonicalJSONString(buf, val)
+
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range val {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeCanonicalJSON(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			writeCanonicalJSONString(buf, key)
+			buf.WriteString(": ")
+			if err := writeCanonicalJSON(buf, val[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	default:
+		return fmt.Errorf("CanonicalJSON: unexpected value type %T", v)
+	}
+	return nil
+}
+
+func canonicalJSONNumber(num json.Number) (string, error) {
+	s := num.String()
+	if !strings.ContainsAny(s, ".eE") {
+		// Integer literals in range are kept as is.
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return s, nil
+		}
+		if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return s, nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", err
+	}
+	return formatJSONDouble(f), nil
+}
+
+// formatJSONDouble formats a double the way MySQL outputs a JSON double: the shortest
+// representation in fixed notation if the decimal exponent is in (-15, 15], otherwise in
+// exponential notation without '+' and zero padding (e.g. "1e20", "1.5e-20"). ".0" is appended
+// when the result contains neither a decimal point nor an exponent.
+func formatJSONDouble(f float64) string {
+	// Find the decimal exponent (the position of the decimal point relative to the first digit).
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := e, 0
+	if i := strings.IndexByte(e, 'e'); i >= 0 {
+		mantissa = e[:i]
+		exp, _ = strconv.Atoi(e[i+1:])
+	}
+	decpt := exp + 1
+
+	var ret string
+	if f == 0 || (decpt > -maxDecptForFixed && decpt <= maxDecptForFixed) {
+		ret = strconv.FormatFloat(f, 'f', -1, 64)
+	} else {
+		ret = mantissa + "e" + strconv.Itoa(exp)
+	}
+	if !strings.ContainsAny(ret, ".e") {
+		ret += ".0"
+	}
+	return ret
+}
+
+// Same as MAX_DECPT_FOR_F_FORMAT in MySQL.
+const maxDecptForFixed = 15
+
+func writeCanonicalJSONString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
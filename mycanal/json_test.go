@@ -0,0 +1,35 @@
+package mycanal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, testCase := range []struct {
+		Text   string
+		Expect string
+	}{
+		{`null`, `null`},
+		{` [ ] `, `[]`},
+		{`{}`, `{}`},
+		{`{"a":"b","c":[]}`, `{"a": "b", "c": []}`},
+		{`{"bb":1,"a":2,"ab":3,"c":{"z":true,"y":false}}`, `{"a": 2, "c": {"y": false, "z": true}, "ab": 3, "bb": 1}`},
+		{`[3.0, 3, -0.0, 1.5, 1e20, 18446744073709551615, 1.0E2]`, `[3.0, 3, -0.0, 1.5, 1e20, 18446744073709551615, 100.0]`},
+		{`[1e15, 1e14, 1e-15, 1e-16, 1.5e-20, -2.5E+30, 0.1]`, `[1e15, 100000000000000.0, 0.000000000000001, 1e-16, 1.5e-20, -2.5e30, 0.1]`},
+		{`[9007199254740993, -9223372036854775808, 18446744073709551616, -9223372036854775809]`, `[9007199254740993, -9223372036854775808, 1.8446744073709552e19, -9.223372036854776e18]`},
+		{`"<&>☃\n\u0001\"\\/"`, "\"<&>☃\\n\\u0001\\\"\\\\/\""},
+	} {
+		result, err := CanonicalJSON(testCase.Text)
+		assert.NoError(err)
+		assert.Equal(testCase.Expect, result, "text %s", testCase.Text)
+	}
+
+	_, err := CanonicalJSON(`{"a":1} 1`)
+	assert.Error(err)
+
+}
@@ -0,0 +1,117 @@
+package mycanal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ValueKind is the kind of column values which can be mapped by ValueMapper.
+type ValueKind int
+
+const (
+	// OtherValue is not mapped.
+	OtherValue ValueKind = iota
+
+	// DecimalValue is DECIMAL/NUMERIC value, string by default.
+	DecimalValue
+
+	// JSONValue is JSON value, string by default.
+	JSONValue
+
+	// DatetimeValue is DATETIME value, time.Time in UTC (the wall clock as is) by default.
+	DatetimeValue
+
+	// TimestampValue is TIMESTAMP value, time.Time in UTC by default. For fulldump, see Config.UTCSession.
+	TimestampValue
+
+	// BitValue is BIT value, binary string by default.
+	BitValue
+)
+
+// ValueMapper maps column values returned by fulldump/incrdump to other representations.
+// The zero value keeps the default representations.
+//
+// Use the same mapper on both sides (fulldump.Options/QueryOptions and incrdump.Options, or
+// runner.Options which applies it to both) to get the same representations.
+type ValueMapper struct {
+	// DecimalAsDecimal returns DECIMAL/NUMERIC values as decimal.Decimal instead of string.
+	DecimalAsDecimal bool
+
+	// JSONAsRawMessage returns JSON values as json.RawMessage instead of string.
+	JSONAsRawMessage bool
+
+	// DatetimeLocation is the location to interpret DATETIME values (which have no time zone) in.
+	// The wall clock is kept. Use UTC if not set.
+	DatetimeLocation *time.Location
+
+	// TimestampLocation is the location to convert TIMESTAMP values to. The instant is kept.
+	// Use UTC if not set.
+	TimestampLocation *time.Location
+
+	// BitAsUint64 returns BIT values as uint64 instead of binary string.
+	BitAsUint64 bool
+
+	// Columns are per-column overrides, key is "schema.table.column". It's not used
+	// for queries whose source table is unknown (e.g. fulldump.Query without Table option).
+	Columns map[string]*ValueMapper
+}
+
+// ForColumn returns the mapper for a column: the override in Columns if exists or m itself.
+func (m *ValueMapper) ForColumn(schema, table, column string) *ValueMapper {
+	if m == nil || len(m.Columns) == 0 {
+		return m
+	}
+	if override, ok := m.Columns[schema+"."+table+"."+column]; ok {
+		return override
+	}
+	return m
+}
+
+// Map maps a non-nil value of the kind in default representation.
+func (m *ValueMapper) Map(kind ValueKind, v interface{}) (interface{}, error) {
+	if m == nil || v == nil {
+		return v, nil
+	}
+
+	switch kind {
+	case DecimalValue:
+		if s, ok := v.(string); ok && m.DecimalAsDecimal {
+			d, err := decimal.NewFromString(s)
+			if err != nil {
+				return nil, fmt.Errorf("ValueMapper: bad decimal %q: %s", s, err)
+			}
+			return d, nil
+		}
+
+	case JSONValue:
+		if s, ok := v.(string); ok && m.JSONAsRawMessage {
+			return json.RawMessage(s), nil
+		}
+
+	case DatetimeValue:
+		if t, ok := v.(time.Time); ok && m.DatetimeLocation != nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), m.DatetimeLocation), nil
+		}
+
+	case TimestampValue:
+		if t, ok := v.(time.Time); ok && m.TimestampLocation != nil {
+			return t.In(m.TimestampLocation), nil
+		}
+
+	case BitValue:
+		if s, ok := v.(string); ok && m.BitAsUint64 {
+			if len(s) > 8 {
+				return nil, fmt.Errorf("ValueMapper: bit value too long (%d bytes)", len(s))
+			}
+			buf := [8]byte{}
+			copy(buf[8-len(s):], s)
+			return binary.BigEndian.Uint64(buf[:]), nil
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,63 @@
+package mycanal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueMapper(t *testing.T) {
+
+	assert := assert.New(t)
+
+	shanghai := time.FixedZone("Asia/Shanghai", 8*3600)
+	dt := time.Date(2020, 2, 20, 20, 20, 20, 0, time.UTC)
+
+	mapper := &ValueMapper{
+		DecimalAsDecimal:  true,
+		JSONAsRawMessage:  true,
+		DatetimeLocation:  shanghai,
+		TimestampLocation: shanghai,
+		BitAsUint64:       true,
+		Columns: map[string]*ValueMapper{
+			"db.t.raw": {},
+		},
+	}
+
+	for i, testCase := range []struct {
+		Kind   ValueKind
+		Value  interface{}
+		Expect interface{}
+	}{
+		{DecimalValue, "-1.50", decimal.RequireFromString("-1.50")},
+		{JSONValue, `{"a": 1}`, json.RawMessage(`{"a": 1}`)},
+		{DatetimeValue, dt, time.Date(2020, 2, 20, 20, 20, 20, 0, shanghai)},
+		{TimestampValue, dt, dt.In(shanghai)},
+		{BitValue, "\x01\x02", uint64(0x0102)},
+		{OtherValue, "x", "x"},
+		{DecimalValue, nil, nil},
+	} {
+		v, err := mapper.Map(testCase.Kind, testCase.Value)
+		assert.NoError(err, "test case %d", i)
+		assert.Equal(testCase.Expect, v, "test case %d", i)
+	}
+
+	// Override.
+	v, err := mapper.ForColumn("db", "t", "raw").Map(DecimalValue, "1.0")
+	assert.NoError(err)
+	assert.Equal("1.0", v)
+	assert.Equal(mapper, mapper.ForColumn("db", "t", "other"))
+
+	// nil mapper.
+	var nilMapper *ValueMapper
+	v, err = nilMapper.ForColumn("db", "t", "raw").Map(DecimalValue, "1.0")
+	assert.NoError(err)
+	assert.Equal("1.0", v)
+
+	_, err = mapper.Map(BitValue, "123456789")
+	assert.Error(err)
+
+}
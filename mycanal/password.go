@@ -0,0 +1,133 @@
+package mycanal
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// PasswordProvider provides the password for a new connection. It's consulted on every new connection
+// of Client() and every (re)connection of the binlog stream, so that rotated passwords are picked up.
+type PasswordProvider interface {
+	Password(ctx context.Context) (string, error)
+}
+
+// PasswordProviderFunc is an adapter to use an ordinary function as PasswordProvider.
+type PasswordProviderFunc func(ctx context.Context) (string, error)
+
+var (
+	_ PasswordProvider = PasswordProviderFunc(nil)
+	_ PasswordProvider = (*FilePasswordProvider)(nil)
+	_ PasswordProvider = (*CommandPasswordProvider)(nil)
+)
+
+// Password implements PasswordProvider.
+func (fn PasswordProviderFunc) Password(ctx context.Context) (string, error) {
+	return fn(ctx)
+}
+
+// FilePasswordProvider reads the password from a file (e.g. a mounted secret). The file is re-read
+// when its modification time or size changes. Trailing newlines are trimmed.
+type FilePasswordProvider struct {
+	// Path of the password file.
+	Path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	size     int64
+	password string
+}
+
+// Password implements PasswordProvider.
+func (p *FilePasswordProvider) Password(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return "", errors.WithMessage(err, "mycanal.FilePasswordProvider")
+	}
+	if !p.modTime.IsZero() && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.password, nil
+	}
+
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return "", errors.WithMessage(err, "mycanal.FilePasswordProvider")
+	}
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	p.password = strings.TrimRight(string(data), "\r\n")
+	return p.password, nil
+}
+
+// CommandPasswordProvider runs a command and uses its stdout as the password. Trailing newlines are trimmed.
+type CommandPasswordProvider struct {
+	// Name and Args of the command.
+	Name string
+	Args []string
+
+	// TTL is the duration to cache the password, 0 to run the command every time.
+	TTL time.Duration
+
+	mu        sync.Mutex
+	expiresAt time.Time
+	password  string
+}
+
+// Password implements PasswordProvider.
+func (p *CommandPasswordProvider) Password(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Now().Before(p.expiresAt) {
+		return p.password, nil
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, p.Name, p.Args...)
+	cmd.Stdout = stdout
+	if err := cmd.Run(); err != nil {
+		return "", errors.WithMessagef(err, "mycanal.CommandPasswordProvider run %s error", p.Name)
+	}
+	p.password = strings.TrimRight(stdout.String(), "\r\n")
+	p.expiresAt = time.Now().Add(p.TTL)
+	return p.password, nil
+}
+
+// passwordConnector is a driver.Connector getting password from PasswordProvider on every connection.
+type passwordConnector struct {
+	driverCfg *mysql.Config
+	provider  PasswordProvider
+}
+
+var (
+	_ driver.Connector = (*passwordConnector)(nil)
+)
+
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.provider.Password(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get password error")
+	}
+	driverCfg := c.driverCfg.Clone()
+	driverCfg.Passwd = password
+	connector, err := mysql.NewConnector(driverCfg)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *passwordConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
@@ -0,0 +1,75 @@
+package mycanal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordProvider(t *testing.T) {
+
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "mycanal")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// File.
+	{
+		path := filepath.Join(dir, "password")
+		assert.NoError(ioutil.WriteFile(path, []byte("p1\n"), 0600))
+		p := &FilePasswordProvider{Path: path}
+
+		password, err := p.Password(ctx)
+		assert.NoError(err)
+		assert.Equal("p1", password)
+
+		// Rotated.
+		assert.NoError(ioutil.WriteFile(path, []byte("p22\n"), 0600))
+		password, err = p.Password(ctx)
+		assert.NoError(err)
+		assert.Equal("p22", password)
+
+		assert.NoError(os.Remove(path))
+		_, err = p.Password(ctx)
+		assert.Error(err)
+	}
+
+	// Command.
+	{
+		p := &CommandPasswordProvider{Name: "echo", Args: []string{"p1"}, TTL: time.Hour}
+		password, err := p.Password(ctx)
+		assert.NoError(err)
+		assert.Equal("p1", password)
+
+		// Cached.
+		p.Args = []string{"p2"}
+		password, err = p.Password(ctx)
+		assert.NoError(err)
+		assert.Equal("p1", password)
+
+		p.expiresAt = time.Time{}
+		password, err = p.Password(ctx)
+		assert.NoError(err)
+		assert.Equal("p2", password)
+
+		_, err = (&CommandPasswordProvider{Name: "false"}).Password(ctx)
+		assert.Error(err)
+	}
+
+	// Func.
+	{
+		p := PasswordProviderFunc(func(ctx context.Context) (string, error) {
+			return "p3", nil
+		})
+		password, err := p.Password(ctx)
+		assert.NoError(err)
+		assert.Equal("p3", password)
+	}
+}
@@ -0,0 +1,286 @@
+package mycanal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Check is the result of a preflight check.
+type Check struct {
+	// Name of the check, e.g. "gtid_mode", "grant REPLICATION SLAVE".
+	Name string `json:"name"`
+
+	// Expect is the expected value.
+	Expect string `json:"expect"`
+
+	// Actual is the actual value.
+	Actual string `json:"actual"`
+
+	// OK is true if the check passed.
+	OK bool `json:"ok"`
+
+	// Remedy is the remediation text for a failing check.
+	Remedy string `json:"remedy,omitempty"`
+}
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Checks []*Check `json:"checks"`
+}
+
+// PreflightOptions is options used in PreflightOpts.
+type PreflightOptions struct {
+	// LockReplica checks privileges needed by fulldump.LockReplica (REPLICATION_SLAVE_ADMIN or SUPER to
+	// stop/start the replica SQL thread) instead of the ones needed to lock tables (RELOAD or BACKUP_ADMIN).
+	LockReplica bool
+}
+
+// Preflight is equivalent to PreflightOpts() with opts == nil.
+func Preflight(ctx context.Context, cfg *Config) (*PreflightReport, error) {
+	return PreflightOpts(ctx, cfg, nil)
+}
+
+// PreflightOpts checks the server prerequisites (see the package doc) and the privileges of cfg.User:
+//   - MySQL-8.0.2 and above
+//   - gtid_mode, enforce_gtid_consistency, log_bin, server_id, binlog_format, binlog_row_image, binlog_row_metadata
+//   - global privileges: REPLICATION SLAVE, REPLICATION CLIENT, SELECT and RELOAD or BACKUP_ADMIN
+//     (REPLICATION_SLAVE_ADMIN or SUPER if opts.LockReplica is set)
+//
+// An error is returned only if the checks can't be run (e.g. connection error). Failing checks are
+// reported in the returned report.
+//
+// NOTE: Privileges granted through roles are not expanded.
+func PreflightOpts(ctx context.Context, cfg *Config, opts *PreflightOptions) (*PreflightReport, error) {
+
+	db, err := cfg.Client()
+	if err != nil {
+		return nil, errors.WithMessage(err, "mycanal.Preflight")
+	}
+	defer db.Close()
+
+	var (
+		version                string
+		gtidMode               string
+		enforceGTIDConsistency string
+		logBin                 string
+		serverId               string
+		binlogFormat           string
+		binlogRowImage         string
+		binlogRowMetadata      string
+	)
+	if err := db.QueryRowContext(
+		ctx,
+		"SELECT VERSION(), @@GLOBAL.gtid_mode, @@GLOBAL.enforce_gtid_consistency, @@GLOBAL.log_bin, "+
+			"@@GLOBAL.server_id, @@GLOBAL.binlog_format, @@GLOBAL.binlog_row_image, @@GLOBAL.binlog_row_metadata",
+	).Scan(
+		&version,
+		&gtidMode,
+		&enforceGTIDConsistency,
+		&logBin,
+		&serverId,
+		&binlogFormat,
+		&binlogRowImage,
+		&binlogRowMetadata,
+	); err != nil {
+		// binlog_row_metadata is not available before MySQL-8.0.1.
+		var ver string
+		if db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&ver) == nil && !versionAtLeast(ver, 8, 0, 2) {
+			return &PreflightReport{
+				Checks: []*Check{versionCheck(ver)},
+			}, nil
+		}
+		return nil, errors.WithMessage(err, "mycanal.Preflight query variables error")
+	}
+
+	report := &PreflightReport{}
+	report.Checks = append(
+		report.Checks,
+		versionCheck(version),
+		variableCheck("gtid_mode", "ON", gtidMode),
+		variableCheck("enforce_gtid_consistency", "ON", enforceGTIDConsistency),
+		&Check{
+			Name:   "log_bin",
+			Expect: "1",
+			Actual: logBin,
+			OK:     logBin == "1",
+			Remedy: "start mysqld with --log-bin",
+		},
+		&Check{
+			Name:   "server_id",
+			Expect: "non-zero",
+			Actual: serverId,
+			OK:     serverId != "0",
+			Remedy: "start mysqld with --server-id=<non-zero>",
+		},
+		variableCheck("binlog_format", "ROW", binlogFormat),
+		variableCheck("binlog_row_image", "FULL", binlogRowImage),
+		variableCheck("binlog_row_metadata", "FULL", binlogRowMetadata),
+	)
+
+	// Privileges.
+	rows, err := db.QueryContext(ctx, "SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		return nil, errors.WithMessage(err, "mycanal.Preflight show grants error")
+	}
+	defer rows.Close()
+	grants := []string{}
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return nil, errors.WithMessage(err, "mycanal.Preflight show grants error")
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithMessage(err, "mycanal.Preflight show grants error")
+	}
+
+	privs := globalPrivileges(grants)
+	report.Checks = append(
+		report.Checks,
+		privilegeCheck(privs, "REPLICATION SLAVE", "needed by incrdump to stream binlog"),
+		privilegeCheck(privs, "REPLICATION CLIENT", "needed by incrdump to query binlog status"),
+		privilegeCheck(privs, "SELECT", "needed by fulldump to read tables"),
+	)
+	if opts.lockReplica() {
+		report.Checks = append(
+			report.Checks,
+			privilegeCheck(privs, "REPLICATION_SLAVE_ADMIN", "needed by fulldump to stop/start the replica SQL thread (LockReplica)", "SUPER"),
+		)
+	} else {
+		report.Checks = append(
+			report.Checks,
+			privilegeCheck(privs, "RELOAD", "needed by fulldump to lock tables (LockFTWRL)", "BACKUP_ADMIN"),
+		)
+	}
+	return report, nil
+}
+
+func (opts *PreflightOptions) lockReplica() bool {
+	return opts != nil && opts.LockReplica
+}
+
+// OK returns true if all checks passed.
+func (report *PreflightReport) OK() bool {
+	return len(report.Failures()) == 0
+}
+
+// Failures returns failing checks.
+func (report *PreflightReport) Failures() []*Check {
+	ret := []*Check{}
+	for _, check := range report.Checks {
+		if !check.OK {
+			ret = append(ret, check)
+		}
+	}
+	return ret
+}
+
+// Err returns an error describing all failing checks, nil if all checks passed.
+func (report *PreflightReport) Err() error {
+	failures := report.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(failures))
+	for _, check := range failures {
+		msgs = append(msgs, check.String())
+	}
+	return errors.Errorf("mycanal preflight failed: %s", strings.Join(msgs, "; "))
+}
+
+// String returns a readable description of the check.
+func (check *Check) String() string {
+	if check.OK {
+		return fmt.Sprintf("%s: ok", check.Name)
+	}
+	return fmt.Sprintf("%s: expect %s but got %s, %s", check.Name, check.Expect, check.Actual, check.Remedy)
+}
+
+func versionCheck(version string) *Check {
+	return &Check{
+		Name:   "version",
+		Expect: ">= 8.0.2",
+		Actual: version,
+		OK:     versionAtLeast(version, 8, 0, 2),
+		Remedy: "upgrade to MySQL-8.0.2 or above",
+	}
+}
+
+func variableCheck(name, expect, actual string) *Check {
+	return &Check{
+		Name:   name,
+		Expect: expect,
+		Actual: actual,
+		OK:     strings.EqualFold(expect, actual),
+		Remedy: fmt.Sprintf("start mysqld with --%s=%s", strings.ReplaceAll(name, "_", "-"), expect),
+	}
+}
+
+func privilegeCheck(privs map[string]bool, priv, usage string, alternatives ...string) *Check {
+	check := &Check{
+		Name:   "grant " + strings.Join(append([]string{priv}, alternatives...), " or "),
+		Expect: "granted on *.*",
+		Actual: "not granted",
+	}
+	for _, p := range append([]string{priv}, alternatives...) {
+		if privs[p] {
+			check.Actual = p + " granted"
+			check.OK = true
+			return check
+		}
+	}
+	check.Remedy = fmt.Sprintf("%s, run 'GRANT %s ON *.* TO <user>'", usage, priv)
+	return check
+}
+
+var (
+	versionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+	grantRegexp   = regexp.MustCompile(`^GRANT (.+) ON (\S+) TO `)
+)
+
+// versionAtLeast returns true if version (e.g. "8.0.19-log") >= major.minor.patch.
+func versionAtLeast(version string, major, minor, patch int) bool {
+	m := versionRegexp.FindStringSubmatch(version)
+	if m == nil {
+		return false
+	}
+	expect := []int{major, minor, patch}
+	for i, s := range m[1:] {
+		n, _ := strconv.Atoi(s)
+		if n != expect[i] {
+			return n > expect[i]
+		}
+	}
+	return true
+}
+
+// staticPrivileges are (part of) privileges included in 'ALL PRIVILEGES'.
+var staticPrivileges = []string{"SELECT", "RELOAD", "REPLICATION SLAVE", "REPLICATION CLIENT", "SUPER"}
+
+// globalPrivileges extracts global (*.*) privileges from the result of 'SHOW GRANTS'.
+func globalPrivileges(grants []string) map[string]bool {
+	ret := map[string]bool{}
+	for _, grant := range grants {
+		m := grantRegexp.FindStringSubmatch(grant)
+		if m == nil || m[2] != "*.*" {
+			continue
+		}
+		for _, priv := range strings.Split(m[1], ",") {
+			priv = strings.ToUpper(strings.TrimSpace(priv))
+			if priv == "ALL" || priv == "ALL PRIVILEGES" {
+				for _, p := range staticPrivileges {
+					ret[p] = true
+				}
+				continue
+			}
+			ret[priv] = true
+		}
+	}
+	return ret
+}
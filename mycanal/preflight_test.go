@@ -0,0 +1,50 @@
+package mycanal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightHelpers(t *testing.T) {
+
+	assert := assert.New(t)
+
+	assert.True(versionAtLeast("8.0.2", 8, 0, 2))
+	assert.True(versionAtLeast("8.0.19-log", 8, 0, 2))
+	assert.True(versionAtLeast("10.0.0", 8, 0, 2))
+	assert.False(versionAtLeast("8.0.1", 8, 0, 2))
+	assert.False(versionAtLeast("5.7.30-log", 8, 0, 2))
+	assert.False(versionAtLeast("x", 8, 0, 2))
+
+	privs := globalPrivileges([]string{
+		"GRANT SELECT, REPLICATION CLIENT ON *.* TO `u`@`%`",
+		"GRANT BACKUP_ADMIN,REPLICATION_SLAVE_ADMIN ON *.* TO `u`@`%`",
+		"GRANT RELOAD ON `db`.* TO `u`@`%`",
+	})
+	assert.Equal(map[string]bool{
+		"SELECT":                  true,
+		"REPLICATION CLIENT":      true,
+		"BACKUP_ADMIN":            true,
+		"REPLICATION_SLAVE_ADMIN": true,
+	}, privs)
+
+	privs = globalPrivileges([]string{"GRANT ALL PRIVILEGES ON *.* TO `root`@`%` WITH GRANT OPTION"})
+	assert.True(privs["REPLICATION SLAVE"])
+	assert.True(privs["RELOAD"])
+	assert.True(privilegeCheck(privs, "REPLICATION_SLAVE_ADMIN", "usage", "SUPER").OK)
+
+	check := privilegeCheck(map[string]bool{"BACKUP_ADMIN": true}, "RELOAD", "usage", "BACKUP_ADMIN")
+	assert.True(check.OK)
+	check = privilegeCheck(map[string]bool{}, "RELOAD", "usage", "BACKUP_ADMIN")
+	assert.False(check.OK)
+	assert.Equal("grant RELOAD or BACKUP_ADMIN", check.Name)
+
+	report := &PreflightReport{Checks: []*Check{
+		{Name: "a", OK: true},
+		variableCheck("binlog_format", "ROW", "STATEMENT"),
+	}}
+	assert.False(report.OK())
+	assert.Len(report.Failures(), 1)
+	assert.EqualError(report.Err(), "mycanal preflight failed: binlog_format: expect ROW but got STATEMENT, start mysqld with --binlog-format=ROW")
+}
@@ -0,0 +1,183 @@
+package mycanal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ColumnAction is the action of a ColumnRule.
+type ColumnAction int
+
+const (
+	// KeepColumn keeps the column as is.
+	KeepColumn ColumnAction = iota
+
+	// DropColumn removes the column.
+	DropColumn
+
+	// HashColumn replaces non-NULL values with hex encoded HMAC-SHA256 (keyed by ColumnRules.HashKey)
+	// of their string representations.
+	HashColumn
+
+	// TruncateColumn truncates string values to ColumnRule.Length characters and []byte values to
+	// ColumnRule.Length bytes. Other values are kept.
+	TruncateColumn
+
+	// ReplaceColumn replaces non-NULL values with ColumnRule.Value.
+	ReplaceColumn
+)
+
+// ColumnRule is the rule of a column.
+type ColumnRule struct {
+	// Action of the rule.
+	Action ColumnAction
+
+	// Length is used by TruncateColumn.
+	Length int
+
+	// Value is used by ReplaceColumn.
+	Value interface{}
+}
+
+// ColumnRules are column projection/masking rules applied to rows of fulldump and incrdump,
+// after values mapped by ValueMapper.
+//
+// NOTE: Values of the same row may have different representations between fulldump and incrdump
+// (see the package doc), use canonical mode if hashes need to be the same.
+type ColumnRules struct {
+	// Columns maps "schema.table.column" to rules.
+	Columns map[string]*ColumnRule
+
+	// HashKey is the key of HMAC used by HashColumn.
+	HashKey []byte
+
+	once   sync.Once
+	tables map[string]*TableRules
+}
+
+// TableRules are rules of a table.
+type TableRules struct {
+	columns map[string]*ColumnRule
+	hashKey []byte
+}
+
+// ForTable returns rules of a table, nil if the table has no rules.
+func (rules *ColumnRules) ForTable(schema, table string) *TableRules {
+	if rules == nil || len(rules.Columns) == 0 {
+		return nil
+	}
+	rules.once.Do(func() {
+		rules.tables = map[string]*TableRules{}
+		for key, rule := range rules.Columns {
+			i := strings.LastIndexByte(key, '.')
+			if i < 0 || rule == nil {
+				continue
+			}
+			t := rules.tables[key[:i]]
+			if t == nil {
+				t = &TableRules{
+					columns: map[string]*ColumnRule{},
+					hashKey: rules.HashKey,
+				}
+				rules.tables[key[:i]] = t
+			}
+			t.columns[key[i+1:]] = rule
+		}
+	})
+	return rules.tables[schema+"."+table]
+}
+
+// Drop returns true if the column should be dropped.
+func (t *TableRules) Drop(column string) bool {
+	if t == nil {
+		return false
+	}
+	rule := t.columns[column]
+	return rule != nil && rule.Action == DropColumn
+}
+
+// Apply applies the rule of a column (if any) to a value. Dropped columns should be checked by Drop.
+func (t *TableRules) Apply(column string, v interface{}) interface{} {
+	if t == nil || v == nil {
+		return v
+	}
+	rule := t.columns[column]
+	if rule == nil {
+		return v
+	}
+
+	switch rule.Action {
+	case HashColumn:
+		mac := hmac.New(sha256.New, t.hashKey)
+		mac.Write(valueBytes(v))
+		return hex.EncodeToString(mac.Sum(nil))
+
+	case TruncateColumn:
+		switch val := v.(type) {
+		case string:
+			if utf8.RuneCountInString(val) <= rule.Length {
+				return val
+			}
+			n := 0
+			for i := range val {
+				if n == rule.Length {
+					return val[:i]
+				}
+				n++
+			}
+			return val
+		case []byte:
+			if len(val) > rule.Length {
+				return val[:rule.Length]
+			}
+			return val
+		}
+		return v
+
+	case ReplaceColumn:
+		return rule.Value
+
+	default:
+		return v
+	}
+}
+
+// ApplyMap applies rules to a data map (column name -> column data) in place.
+func (t *TableRules) ApplyMap(data map[string]interface{}) {
+	if t == nil {
+		return
+	}
+	for column := range t.columns {
+		v, ok := data[column]
+		if !ok {
+			continue
+		}
+		if t.Drop(column) {
+			delete(data, column)
+			continue
+		}
+		data[column] = t.Apply(column, v)
+	}
+}
+
+// valueBytes returns the string representation of a value to hash.
+func valueBytes(v interface{}) []byte {
+	switch val := v.(type) {
+	case string:
+		return []byte(val)
+	case []byte:
+		return val
+	case time.Time:
+		return []byte(val.UTC().Format(time.RFC3339Nano))
+	case Geometry:
+		return val.Bytes()
+	default:
+		return []byte(fmt.Sprint(val))
+	}
+}
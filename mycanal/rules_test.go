@@ -0,0 +1,70 @@
+package mycanal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnRules(t *testing.T) {
+
+	assert := assert.New(t)
+
+	hash := func(s string) string {
+		mac := hmac.New(sha256.New, []byte("key"))
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	rules := &ColumnRules{
+		Columns: map[string]*ColumnRule{
+			"db.user.ssn":   {Action: DropColumn},
+			"db.user.email": {Action: HashColumn},
+			"db.user.age":   {Action: HashColumn},
+			"db.user.bio":   {Action: TruncateColumn, Length: 3},
+			"db.user.photo": {Action: TruncateColumn, Length: 2},
+			"db.user.note":  {Action: ReplaceColumn, Value: "***"},
+			"db.other.id":   {Action: DropColumn},
+		},
+		HashKey: []byte("key"),
+	}
+
+	assert.Nil(rules.ForTable("db", "nothing"))
+	assert.Nil((*ColumnRules)(nil).ForTable("db", "user"))
+
+	table := rules.ForTable("db", "user")
+	assert.True(table.Drop("ssn"))
+	assert.False(table.Drop("email"))
+	assert.False(table.Drop("id"))
+
+	data := map[string]interface{}{
+		"id":    int32(1),
+		"ssn":   "123-45-6789",
+		"email": "a@b.c",
+		"age":   int8(30),
+		"bio":   "你好世界",
+		"photo": []byte{1, 2, 3},
+		"note":  "secret",
+	}
+	table.ApplyMap(data)
+	assert.Equal(map[string]interface{}{
+		"id":    int32(1),
+		"email": hash("a@b.c"),
+		"age":   hash("30"),
+		"bio":   "你好世",
+		"photo": []byte{1, 2},
+		"note":  "***",
+	}, data)
+
+	// NULL is kept.
+	assert.Nil(table.Apply("email", nil))
+	assert.Nil(table.Apply("note", nil))
+
+	// No rules.
+	var noRules *TableRules
+	assert.False(noRules.Drop("ssn"))
+	assert.Equal("x", noRules.Apply("note", "x"))
+}
@@ -0,0 +1,6 @@
+// Package runner glues fulldump and incrdump together: it takes a consistent snapshot of
+// selected tables, then streams binlog events from exactly the GTID set of the snapshot,
+// so that there is no gap or overlap between the two phases.
+//
+// NOTE: It's not in package mycanal since fulldump/incrdump import mycanal.
+package runner
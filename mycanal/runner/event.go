@@ -0,0 +1,23 @@
+package runner
+
+import (
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+// RowSnapshot is a row read in the snapshot phase.
+type RowSnapshot struct {
+	// Table is the table of the row.
+	Table *fulldump.TableInfo
+
+	// DataMap is the row data (column name -> column data).
+	DataMap map[string]interface{}
+}
+
+// SnapshotEnding is emitted after all RowSnapshot and before any binlog event.
+//
+// Persist GTIDSet as Options.Checkpoint (and keep updating it with TrxContext.AfterGTIDSet()
+// of each TrxEnding) to skip the snapshot after restart.
+type SnapshotEnding struct {
+	// GTIDSet is the gtid set of the snapshot, binlog streaming starts from it.
+	GTIDSet string
+}
@@ -0,0 +1,191 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/logr"
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+var (
+	// DefaultLogger is the default value of Options.Logger.
+	DefaultLogger = logr.Nop
+)
+
+// Options is options used in Run.
+type Options struct {
+	// Tables selects tables to snapshot. nil to select all tables (see fulldump.ListTables).
+	Tables *fulldump.TableFilter
+
+	// Dump is the options used to take the snapshot, optional. Dump.Throttle (if any) is applied to
+	// snapshot rows and progress is tracked if Dump.Progress or Dump.OnProgress is set.
+	// Canonical, TimeAsString, ValueMapper and ColumnRules in it are ignored, see below.
+	Dump *fulldump.Options
+
+	// Incr is the options used to stream binlog events, optional.
+	Incr *incrdump.Options
+
+	// Canonical, TimeAsString and ValueMapper are applied to both snapshot rows and binlog row changes,
+	// so that the handler receives the same representation of a column in both phases. Those in Incr
+	// are used if not set here.
+	Canonical    bool
+	TimeAsString bool
+	ValueMapper  *ValueMapper
+
+	// ColumnRules, if not nil, are applied to both snapshot rows and binlog row changes
+	// (overriding Incr.ColumnRules). Incr.ColumnRules (if any) are applied to both if not set.
+	ColumnRules *ColumnRules
+
+	// Checkpoint is the gtid set persisted by the handler. If not empty, the snapshot phase is
+	// skipped and binlog streaming starts from it directly.
+	Checkpoint string
+
+	// Logger for logging.
+	//
+	// Use DefaultLogger if not set.
+	Logger logr.Logger
+}
+
+// Run snapshots tables and then streams binlog events from the GTID set of the snapshot,
+// using the same handler. Events can be one of the followings:
+//
+//   - *RowSnapshot: a row in the snapshot
+//   - *SnapshotEnding: the end of the snapshot, emitted once after all RowSnapshot
+//   - events of incrdump.Handler
+//
+// If opts.Checkpoint is not empty, only events of incrdump.Handler are emitted.
+func Run(ctx context.Context, cfg *Config, opts *Options, handler incrdump.Handler) error {
+
+	if opts == nil {
+		opts = &Options{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = DefaultLogger
+	}
+
+	gtidSet := opts.Checkpoint
+	if gtidSet == "" {
+		var err error
+		gtidSet, err = snapshot(ctx, cfg, opts, handler)
+		if err != nil {
+			return err
+		}
+		logger.Info("runner snapshot end", "gtidSet", gtidSet)
+
+		if err := handler(ctx, &SnapshotEnding{
+			GTIDSet: gtidSet,
+		}); err != nil {
+			return err
+		}
+	} else {
+		logger.Info("runner resume from checkpoint", "gtidSet", gtidSet)
+	}
+
+	return incrdump.IncrDumpOpts(ctx, cfg, gtidSet, opts.incrOptions(), handler)
+}
+
+func snapshot(ctx context.Context, cfg *Config, opts *Options, handler incrdump.Handler) (string, error) {
+
+	dumpOpts := opts.dumpOptions()
+	queryOpts := opts.queryOptions()
+	gtidSet, err := fulldump.FullDumpOpts(ctx, cfg, dumpOpts, func(ctx context.Context, q sqlh.Queryer) error {
+		progress := dumpOpts.Progress
+		if progress != nil {
+			tables, err := fulldump.ListTables(ctx, q, opts.Tables)
+			if err != nil {
+				return err
+			}
+			refs := make([]fulldump.TableRef, len(tables))
+			for i, table := range tables {
+				refs[i] = table.TableRef
+			}
+			if err := progress.AddTables(ctx, q, refs); err != nil {
+				return err
+			}
+		}
+
+		return fulldump.DumpSchemas(ctx, q, opts.Tables, queryOpts, func(ctx context.Context, e interface{}) error {
+			ev, ok := e.(*fulldump.TableRows)
+			if !ok {
+				return nil
+			}
+			iter := ev.Iter
+			if progress != nil {
+				iter = progress.Track(ev.TableRef, iter)
+			}
+			if dumpOpts.Throttle != nil {
+				iter = dumpOpts.Throttle.Wrap(ctx, iter)
+			}
+			for {
+				row, err := iter(true)
+				if err != nil {
+					return err
+				}
+				if row == nil {
+					return nil
+				}
+				if err := handler(ctx, &RowSnapshot{
+					Table:   ev.TableInfo,
+					DataMap: row,
+				}); err != nil {
+					return err
+				}
+			}
+		})
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "runner.Run snapshot error")
+	}
+	return gtidSet, nil
+}
+
+// dumpOptions returns options for fulldump.FullDumpOpts: value options and column rules are
+// applied by queryOptions instead, and a progress tracker is created if only OnProgress is set.
+func (opts *Options) dumpOptions() *fulldump.Options {
+	ret := fulldump.Options{}
+	if opts.Dump != nil {
+		ret = *opts.Dump
+	}
+	ret.Canonical = false
+	ret.TimeAsString = false
+	ret.ValueMapper = nil
+	ret.ColumnRules = nil
+	if ret.Progress == nil && ret.OnProgress != nil {
+		ret.Progress = fulldump.NewProgressTracker()
+	}
+	return &ret
+}
+
+// queryOptions returns options to query snapshot rows.
+func (opts *Options) queryOptions() *fulldump.QueryOptions {
+	incr := opts.incrOptions()
+	return &fulldump.QueryOptions{
+		Canonical:    incr.Canonical,
+		TimeAsString: incr.TimeAsString,
+		ValueMapper:  incr.ValueMapper,
+		ColumnRules:  incr.ColumnRules,
+	}
+}
+
+// incrOptions returns options for incrdump.IncrDumpOpts.
+func (opts *Options) incrOptions() *incrdump.Options {
+	ret := incrdump.Options{}
+	if opts.Incr != nil {
+		ret = *opts.Incr
+	}
+	ret.Canonical = ret.Canonical || opts.Canonical
+	ret.TimeAsString = ret.TimeAsString || opts.TimeAsString
+	if opts.ValueMapper != nil {
+		ret.ValueMapper = opts.ValueMapper
+	}
+	if opts.ColumnRules != nil {
+		ret.ColumnRules = opts.ColumnRules
+	}
+	return &ret
+}
@@ -0,0 +1,194 @@
+package mycanal
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/huangjunwen/golibs/logr"
+)
+
+var (
+	// DefaultServerIdMin is the default value of ServerIdOptions.Min.
+	DefaultServerIdMin uint32 = 1 << 20
+
+	// DefaultServerIdMax is the default value of ServerIdOptions.Max.
+	DefaultServerIdMax uint32 = 1<<32 - 1
+
+	// DefaultServerIdRetries is the default value of ServerIdOptions.Retries.
+	DefaultServerIdRetries = 10
+
+	// DefaultServerIdLogger is the default value of ServerIdOptions.Logger.
+	DefaultServerIdLogger = logr.Nop
+)
+
+var (
+	errUnregisteredDumpClients = errors.New("found unregistered binlog dump clients whose server ids are unknown")
+)
+
+// ServerIdOptions is options used in AllocServerId.
+type ServerIdOptions struct {
+	// Min and Max is the range ([Min, Max]) to pick server id from.
+	//
+	// Use DefaultServerIdMin/DefaultServerIdMax if not set.
+	Min uint32
+	Max uint32
+
+	// Retries is the max retries if the picked id is in use.
+	//
+	// Use DefaultServerIdRetries if not set.
+	Retries int
+
+	// Logger for logging.
+	//
+	// Use DefaultServerIdLogger if not set.
+	Logger logr.Logger
+}
+
+// AllocServerId picks a random server id in the range which is not in use: not the server's own server_id
+// and not the one of any connected replica (SHOW REPLICAS/SHOW SLAVE HOSTS, which includes all binlog
+// dump clients registered, such as incrdump).
+//
+// NOTE: Binlog dump clients not registered as replicas (e.g. mysqlbinlog --read-from-remote-server) are
+// NOT detected: their server ids are not exposed anywhere. AllocServerId cross-checks binlog dump threads
+// (performance_schema.threads) against the registered replicas and logs a warning if there are
+// unregistered ones, in which case the picked id may still be in use (MySQL kills the existing dump
+// thread of the same server id when a new one starts). Two clients allocating at the same time may
+// also collide, but it's unlikely with a large range.
+func AllocServerId(ctx context.Context, cfg *Config, opts *ServerIdOptions) (uint32, error) {
+
+	min, max := opts.min(), opts.max()
+	if min == 0 || min > max {
+		return 0, errors.Errorf("mycanal.AllocServerId: invalid range [%d, %d]", min, max)
+	}
+
+	db, err := cfg.Client()
+	if err != nil {
+		return 0, errors.WithMessage(err, "mycanal.AllocServerId")
+	}
+	defer db.Close()
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i <= opts.retries(); i++ {
+		used, registered, err := usedServerIds(ctx, db)
+		if err != nil {
+			return 0, errors.WithMessage(err, "mycanal.AllocServerId")
+		}
+		if i == 0 {
+			checkDumpThreads(ctx, db, registered, opts.logger())
+		}
+
+		id := min + uint32(r.Int63n(int64(max)-int64(min)+1))
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, errors.Errorf("mycanal.AllocServerId: no free server id after %d retries", opts.retries())
+}
+
+// checkDumpThreads logs a warning if there are more binlog dump threads than registered replicas.
+func checkDumpThreads(ctx context.Context, db *sql.DB, registered int, logger logr.Logger) {
+	var n int
+	if err := db.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM performance_schema.threads WHERE PROCESSLIST_COMMAND LIKE 'Binlog Dump%'",
+	).Scan(&n); err != nil {
+		logger.Error(err, "mycanal.AllocServerId count binlog dump threads error")
+		return
+	}
+	if n > registered {
+		// NOTE: logr.Logger has no warning level, use Error so that it's not filtered as verbose info.
+		logger.Error(
+			errUnregisteredDumpClients,
+			"mycanal.AllocServerId picked server id may be in use",
+			"dumpThreads", n,
+			"registeredReplicas", registered,
+		)
+	}
+}
+
+// usedServerIds returns server ids of the server and its replicas, and the number of registered replicas.
+func usedServerIds(ctx context.Context, db *sql.DB) (used map[uint32]bool, registered int, err error) {
+	ret := map[uint32]bool{}
+
+	var serverId uint32
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.server_id").Scan(&serverId); err != nil {
+		return nil, 0, errors.WithMessage(err, "query server_id error")
+	}
+	ret[serverId] = true
+
+	// 'SHOW REPLICAS' is available since MySQL-8.0.22.
+	rows, err := db.QueryContext(ctx, "SHOW REPLICAS")
+	if err != nil {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE HOSTS")
+	}
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "show replicas error")
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "show replicas error")
+	}
+	idx := -1
+	for i, name := range names {
+		if strings.EqualFold(name, "Server_id") {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, 0, errors.New("show replicas error: no Server_id column")
+	}
+
+	values := make([]sql.NullString, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, 0, errors.WithMessage(err, "show replicas error")
+		}
+		id, err := strconv.ParseUint(values[idx].String, 10, 32)
+		if err != nil {
+			return nil, 0, errors.WithMessage(err, "show replicas error")
+		}
+		ret[uint32(id)] = true
+		registered++
+	}
+	return ret, registered, errors.WithMessage(rows.Err(), "show replicas error")
+}
+
+func (opts *ServerIdOptions) min() uint32 {
+	if opts != nil && opts.Min > 0 {
+		return opts.Min
+	}
+	return DefaultServerIdMin
+}
+
+func (opts *ServerIdOptions) max() uint32 {
+	if opts != nil && opts.Max > 0 {
+		return opts.Max
+	}
+	return DefaultServerIdMax
+}
+
+func (opts *ServerIdOptions) retries() int {
+	if opts != nil && opts.Retries > 0 {
+		return opts.Retries
+	}
+	return DefaultServerIdRetries
+}
+
+func (opts *ServerIdOptions) logger() logr.Logger {
+	if opts != nil && opts.Logger != nil {
+		return opts.Logger
+	}
+	return DefaultServerIdLogger
+}
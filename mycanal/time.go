@@ -0,0 +1,81 @@
+package mycanal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxTime is the max value of MySQL TIME: 838:59:59.
+	MaxTime = 838*time.Hour + 59*time.Minute + 59*time.Second
+
+	// MinTime is the min value of MySQL TIME: -838:59:59.
+	MinTime = -MaxTime
+)
+
+// ParseTime parses a MySQL TIME value in "[-]HH:MM:SS[.fraction]" format (e.g. "-838:59:59", "20:20:20.123456").
+func ParseTime(s string) (time.Duration, error) {
+	str := s
+	neg := false
+	if strings.HasPrefix(str, "-") {
+		neg = true
+		str = str[1:]
+	}
+
+	parts := strings.Split(str, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("ParseTime: bad TIME value %q", s)
+	}
+
+	frac := ""
+	if i := strings.IndexByte(parts[2], '.'); i >= 0 {
+		parts[2], frac = parts[2][:i], parts[2][i+1:]
+	}
+	if len(frac) > 9 {
+		return 0, fmt.Errorf("ParseTime: bad TIME value %q", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		n, err := strconv.ParseUint(parts[i], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("ParseTime: bad TIME value %q", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	if frac != "" {
+		n, err := strconv.ParseUint(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("ParseTime: bad TIME value %q", s)
+		}
+		d += time.Duration(n)
+	}
+
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// FormatTime formats d in MySQL TIME format with fsp (0~6) fractional digits, e.g. "-838:59:59", "20:20:20.123456".
+func FormatTime(d time.Duration, fsp int) string {
+	b := &strings.Builder{}
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+
+	secs := int64(d / time.Second)
+	fmt.Fprintf(b, "%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	if fsp > 0 {
+		if fsp > 6 {
+			fsp = 6
+		}
+		micros := fmt.Sprintf("%06d", int64(d%time.Second/time.Microsecond))
+		b.WriteByte('.')
+		b.WriteString(micros[:fsp])
+	}
+	return b.String()
+}
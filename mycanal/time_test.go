@@ -0,0 +1,37 @@
+package mycanal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTime(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, testCase := range []struct {
+		Text   string
+		Fsp    int
+		Expect time.Duration
+	}{
+		{"00:00:00", 0, 0},
+		{"20:20:20", 0, 20*time.Hour + 20*time.Minute + 20*time.Second},
+		{"20:20:20.123456", 6, 20*time.Hour + 20*time.Minute + 20*time.Second + 123456*time.Microsecond},
+		{"-00:00:00.5", 1, -500 * time.Millisecond},
+		{"838:59:59", 0, MaxTime},
+		{"-838:59:59.000", 3, MinTime},
+	} {
+		d, err := ParseTime(testCase.Text)
+		assert.NoError(err)
+		assert.Equal(testCase.Expect, d, "text %s", testCase.Text)
+		assert.Equal(testCase.Text, FormatTime(d, testCase.Fsp))
+	}
+
+	for _, text := range []string{"", "1:2", "a:00:00", "00:00:00.1234567890", "00:-1:00"} {
+		_, err := ParseTime(text)
+		assert.Error(err, "text %q", text)
+	}
+
+}
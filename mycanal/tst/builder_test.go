@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+func TestTableQueryPaginate(t *testing.T) {
+
+	assert := assert.New(t)
+
+	_, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err := db.Exec("CREATE TABLE tst.`select` (tenant int, id int, v varchar(16), primary key (tenant, id))")
+	if err != nil {
+		log.Panic(err)
+	}
+	for i := 0; i < 50; i++ {
+		_, err = db.Exec("INSERT INTO tst.`select` VALUES (?, ?, 'x')", i%3, i)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	tq := &fulldump.TableQuery{
+		Table:   fulldump.TableRef{Schema: "tst", Table: "select"},
+		Columns: []string{"v"},
+		Where:   "tenant = ?",
+		Args:    []interface{}{1},
+	}
+
+	// Read some rows and then resume.
+	ids := []int32{}
+	iter, lastKey, err := tq.Paginate(context.Background(), db, 4)
+	assert.NoError(err)
+	for i := 0; i < 6; i++ {
+		row, err := iter(true)
+		assert.NoError(err)
+		ids = append(ids, row["id"].(int32))
+	}
+	_, err = iter(false)
+	assert.NoError(err)
+
+	tq.After = lastKey()
+	iter, _, err = tq.Paginate(context.Background(), db, 4)
+	assert.NoError(err)
+	defer iter(false)
+	for {
+		row, err := iter(true)
+		assert.NoError(err)
+		if row == nil {
+			break
+		}
+		assert.Equal("x", row["v"])
+		ids = append(ids, row["id"].(int32))
+	}
+
+	expect := []int32{}
+	for i := 1; i < 50; i += 3 {
+		expect = append(expect, int32(i))
+	}
+	assert.Equal(expect, ids)
+
+}
+
+// TestTableQueryPaginateConvertedKey checks pagination on primary keys whose values are converted
+// (TIME to time.Duration, DATETIME to another location).
+func TestTableQueryPaginateConvertedKey(t *testing.T) {
+
+	assert := assert.New(t)
+
+	_, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err := db.Exec("CREATE TABLE tst.converted_key (t time, dt datetime, primary key (t, dt))")
+	if err != nil {
+		log.Panic(err)
+	}
+	for i := 0; i < 10; i++ {
+		_, err = db.Exec("INSERT INTO tst.converted_key VALUES (SEC_TO_TIME(?), '2021-01-02 03:04:05')", i*3600)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	loc := time.FixedZone("UTC+8", 8*3600)
+	tq := &fulldump.TableQuery{
+		Table:       fulldump.TableRef{Schema: "tst", Table: "converted_key"},
+		ValueMapper: &ValueMapper{DatetimeLocation: loc},
+	}
+
+	ts := []time.Duration{}
+	iter, lastKey, err := tq.Paginate(context.Background(), db, 3)
+	assert.NoError(err)
+	for i := 0; i < 4; i++ {
+		row, err := iter(true)
+		assert.NoError(err)
+		ts = append(ts, row["t"].(time.Duration))
+		assert.True(time.Date(2021, 1, 2, 3, 4, 5, 0, loc).Equal(row["dt"].(time.Time)))
+	}
+	_, err = iter(false)
+	assert.NoError(err)
+
+	tq.After = lastKey()
+	iter, _, err = tq.Paginate(context.Background(), db, 3)
+	assert.NoError(err)
+	defer iter(false)
+	for {
+		row, err := iter(true)
+		assert.NoError(err)
+		if row == nil {
+			break
+		}
+		ts = append(ts, row["t"].(time.Duration))
+	}
+
+	expect := []time.Duration{}
+	for i := 0; i < 10; i++ {
+		expect = append(expect, time.Duration(i)*time.Hour)
+	}
+	assert.Equal(expect, ts)
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/dumpfile"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+func TestDumpFile(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	for _, stmt := range []string{
+		"CREATE TABLE tst.files (" +
+			"id int primary key, " +
+			"u64 bigint unsigned, " +
+			"s varchar(16), " +
+			"b varbinary(16), " +
+			"bl blob, " +
+			"dt datetime(6), " +
+			"d decimal(10, 2), " +
+			"j json)",
+		"INSERT INTO tst.files VALUES (1, 18446744073709551615, '', x'00ff', x'', '2020-01-02 03:04:05.123456', 1.5, '{\"a\": 1}')",
+		"INSERT INTO tst.files VALUES (2, NULL, NULL, NULL, NULL, NULL, NULL, NULL)",
+		"INSERT INTO tst.files VALUES (3, 0, '\\\\N', x'5c', x'0a0d', '1000-01-01 00:00:00', -0.01, '[]')",
+		"CREATE DATABASE tst2",
+		"CREATE TABLE tst2.files LIKE tst.files",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	dumpTable := func(schema string) []map[string]interface{} {
+		iter, err := fulldump.FullTableQuery(context.Background(), db, schema, "files")
+		if err != nil {
+			log.Panic(err)
+		}
+		defer iter(false)
+		ret := []map[string]interface{}{}
+		for {
+			row, err := iter(true)
+			if err != nil {
+				log.Panic(err)
+			}
+			if row == nil {
+				return ret
+			}
+			ret = append(ret, row)
+		}
+	}
+	expect := dumpTable("tst")
+
+	for _, format := range []dumpfile.Format{dumpfile.FormatNDJSON, dumpfile.FormatCSV} {
+		dir, err := ioutil.TempDir("", "dumpfile")
+		if err != nil {
+			log.Panic(err)
+		}
+		defer os.RemoveAll(dir)
+
+		manifest, err := dumpfile.Export(context.Background(), cfg, dir, &dumpfile.ExportOptions{
+			Format: format,
+			Tables: &fulldump.TableFilter{Include: []string{"tst.files"}},
+		})
+		assert.NoError(err)
+		assert.NotEmpty(manifest.GTIDSet)
+		assert.Len(manifest.Tables, 1)
+		assert.Equal(int64(3), manifest.Tables[0].Rows)
+
+		_, err = db.Exec("DELETE FROM tst2.files")
+		assert.NoError(err)
+		_, err = dumpfile.Load(context.Background(), db, dir, &dumpfile.LoadOptions{
+			BatchSize: 2,
+			Schema:    "tst2",
+		})
+		assert.NoError(err)
+		assert.Equal(expect, dumpTable("tst2"), "format %s", format)
+	}
+
+}
+
+func TestDumpFileColumnRules(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	for _, stmt := range []string{
+		"CREATE TABLE tst.pii (id int primary key, email varchar(64), phone varchar(32))",
+		"INSERT INTO tst.pii VALUES (1, 'a@example.com', '12345678')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "dumpfile")
+	if err != nil {
+		log.Panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := dumpfile.Export(context.Background(), cfg, dir, &dumpfile.ExportOptions{
+		Tables: &fulldump.TableFilter{Include: []string{"tst.pii"}},
+		Dump: &fulldump.Options{
+			ColumnRules: &ColumnRules{
+				Columns: map[string]*ColumnRule{
+					"tst.pii.email": {Action: HashColumn},
+					"tst.pii.phone": {Action: DropColumn},
+				},
+				HashKey: []byte("key"),
+			},
+		},
+	})
+	assert.NoError(err)
+	if !assert.Len(manifest.Tables, 1) {
+		return
+	}
+	tm := manifest.Tables[0]
+	names := []string{}
+	for _, col := range tm.Columns {
+		names = append(names, col.Name)
+	}
+	assert.Equal([]string{"id", "email"}, names)
+	assert.Equal(int64(1), tm.Rows)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tm.File))
+	assert.NoError(err)
+	assert.NotContains(string(data), "a@example.com")
+	assert.NotContains(string(data), "12345678")
+}
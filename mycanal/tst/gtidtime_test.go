@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+)
+
+func TestGTIDSetAt(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := db.Exec("CREATE TABLE tst.gtidtime (id int primary key)")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	gtidExecuted := func() string {
+		var gtidSet string
+		if err := db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet); err != nil {
+			log.Panic(err)
+		}
+		return gtidSet
+	}
+
+	// Several trxs in several binlog files, with a cutoff time after each.
+	gtidSets := []string{}
+	cutoffs := []time.Time{}
+	for i := 0; i < 6; i++ {
+		_, err = db.Exec("INSERT INTO tst.gtidtime VALUES (?)", i)
+		if err != nil {
+			log.Panic(err)
+		}
+		gtidSets = append(gtidSets, gtidExecuted())
+
+		// Binlog file creation time is in seconds.
+		time.Sleep(1100 * time.Millisecond)
+		cutoffs = append(cutoffs, time.Now())
+		time.Sleep(1100 * time.Millisecond)
+
+		if i%2 == 1 {
+			_, err = db.Exec("FLUSH BINARY LOGS")
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+	}
+
+	equal := func(expect, actual string) {
+		expectGset, err := mysql.ParseMysqlGTIDSet(expect)
+		if err != nil {
+			log.Panic(err)
+		}
+		actualGset, err := mysql.ParseMysqlGTIDSet(actual)
+		if err != nil {
+			log.Panic(err)
+		}
+		assert.True(expectGset.Equal(actualGset), "expect %s, got %s", expect, actual)
+	}
+
+	for i, cutoff := range cutoffs {
+		gtidSet, err := incrdump.GTIDSetAt(ctx, cfg, cutoff)
+		assert.NoError(err)
+		equal(gtidSets[i], gtidSet)
+	}
+
+	// Now.
+	gtidSet, err := incrdump.GTIDSetAt(ctx, cfg, time.Now())
+	assert.NoError(err)
+	equal(gtidExecuted(), gtidSet)
+
+	// Before all binlogs.
+	_, err = incrdump.GTIDSetAt(ctx, cfg, time.Now().Add(-24*time.Hour))
+	assert.Equal(incrdump.ErrTimeBeforeBinlogs, err)
+
+	// Resume from the gtid set.
+	gtidSet, err = incrdump.GTIDSetAt(ctx, cfg, cutoffs[2])
+	assert.NoError(err)
+	ids := []int32{}
+	err = incrdump.IncrDumpOpts(ctx, cfg, gtidSet, &incrdump.Options{StopAtGTIDSet: gtidSets[5]}, func(ctx context.Context, e interface{}) error {
+		if ev, ok := e.(*incrdump.RowInsertion); ok {
+			ids = append(ids, ev.AfterDataMap()["id"].(int32))
+		}
+		return nil
+	})
+	assert.Equal(incrdump.ErrStopped, err)
+	assert.Equal([]int32{3, 4, 5}, ids)
+}
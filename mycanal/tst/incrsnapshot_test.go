@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+	"github.com/huangjunwen/golibs/mycanal/incrsnapshot"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+func TestIncrSnapshot(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec("CREATE TABLE tst.snap (id int primary key, v int)")
+	if err != nil {
+		log.Panic(err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec("INSERT INTO tst.snap (id, v) VALUES (?, ?)", i, i)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	gtidSet, err := fulldump.FullDump(context.Background(), cfg, func(ctx context.Context, q sqlh.Queryer) error {
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	snapshotter, err := incrsnapshot.New(cfg, &incrsnapshot.Options{
+		SignalTable: fulldump.TableRef{Schema: "tst", Table: "signal"},
+		ChunkSize:   7,
+	})
+	assert.NoError(err)
+
+	// Concurrent writes during snapshot.
+	go func() {
+		for i := 0; i < 100; i += 3 {
+			db.Exec("UPDATE tst.snap SET v = v + 1000 WHERE id = ?", i)
+		}
+	}()
+
+	// Final state of the table built from the stream.
+	state := map[int32]int32{}
+	chunks := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	snapshotter.Snapshot(fulldump.TableRef{Schema: "tst", Table: "snap"}, nil)
+	err = snapshotter.Run(ctx, gtidSet, func(ctx context.Context, e interface{}) error {
+		switch ev := e.(type) {
+		case *incrsnapshot.RowSnapshot:
+			state[ev.DataMap["id"].(int32)] = ev.DataMap["v"].(int32)
+
+		case *incrsnapshot.ChunkEnding:
+			chunks++
+			if ev.Done {
+				// Let the updates catch up.
+				time.AfterFunc(3*time.Second, cancel)
+			}
+
+		case *incrdump.RowInsertion:
+			data := ev.AfterDataMap()
+			state[data["id"].(int32)] = data["v"].(int32)
+
+		case *incrdump.RowUpdating:
+			data := ev.AfterDataMap()
+			state[data["id"].(int32)] = data["v"].(int32)
+
+		case incrdump.RowChange:
+			assert.NotEqual("signal", ev.TableName())
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(15, chunks)
+
+	rows, err := db.Query("SELECT id, v FROM tst.snap")
+	assert.NoError(err)
+	defer rows.Close()
+	expect := map[int32]int32{}
+	for rows.Next() {
+		var id, v int32
+		assert.NoError(rows.Scan(&id, &v))
+		expect[id] = v
+	}
+	assert.Equal(expect, state)
+
+}
+
+// TestIncrSnapshotKeys checks conflicts are detected for primary keys whose values are
+// represented differently in snapshot and binlog.
+func TestIncrSnapshotKeys(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec("CREATE TABLE tst.snapkey (dt datetime(3), d decimal(10,2), v int, primary key (dt, d))")
+	if err != nil {
+		log.Panic(err)
+	}
+	base := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		_, err = db.Exec("INSERT INTO tst.snapkey VALUES (?, ?, ?)", base.Add(time.Duration(i)*time.Second), fmt.Sprintf("%d.50", i), i)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	gtidSet, err := fulldump.FullDump(context.Background(), cfg, func(ctx context.Context, q sqlh.Queryer) error {
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	snapshotter, err := incrsnapshot.New(cfg, &incrsnapshot.Options{
+		SignalTable: fulldump.TableRef{Schema: "tst", Table: "signal"},
+		ChunkSize:   5,
+	})
+	assert.NoError(err)
+
+	// Concurrent writes during snapshot.
+	go func() {
+		for i := 0; i < 60; i += 2 {
+			db.Exec("UPDATE tst.snapkey SET v = v + 1000 WHERE d = ?", fmt.Sprintf("%d.50", i))
+		}
+	}()
+
+	key := func(data map[string]interface{}) string {
+		d, err := decimal.NewFromString(data["d"].(string))
+		if err != nil {
+			log.Panic(err)
+		}
+		return data["dt"].(time.Time).UTC().Format(time.RFC3339Nano) + " " + d.String()
+	}
+
+	state := map[string]int32{}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	snapshotter.Snapshot(fulldump.TableRef{Schema: "tst", Table: "snapkey"}, nil)
+	err = snapshotter.Run(ctx, gtidSet, func(ctx context.Context, e interface{}) error {
+		switch ev := e.(type) {
+		case *incrsnapshot.RowSnapshot:
+			state[key(ev.DataMap)] = ev.DataMap["v"].(int32)
+
+		case *incrsnapshot.ChunkEnding:
+			if ev.Done {
+				time.AfterFunc(3*time.Second, cancel)
+			}
+
+		case *incrdump.RowUpdating:
+			data := ev.AfterDataMap()
+			state[key(data)] = data["v"].(int32)
+		}
+		return nil
+	})
+	assert.NoError(err)
+
+	iter, err := fulldump.Query(context.Background(), db, "SELECT * FROM tst.snapkey")
+	assert.NoError(err)
+	defer iter(false)
+	expect := map[string]int32{}
+	for {
+		row, err := iter(true)
+		assert.NoError(err)
+		if row == nil {
+			break
+		}
+		expect[key(row)] = row["v"].(int32)
+	}
+	assert.Equal(expect, state)
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+func TestLockMode(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	var expect string
+	assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&expect))
+
+	for _, mode := range []fulldump.LockMode{
+		fulldump.LockAuto,
+		fulldump.LockFTWRL,
+		fulldump.LockBackup,
+		fulldump.LockNone,
+	} {
+		called := false
+		gtidSet, err := fulldump.FullDumpOpts(
+			context.Background(),
+			cfg,
+			&fulldump.Options{Lock: mode},
+			func(ctx context.Context, q sqlh.Queryer) error {
+				called = true
+				return nil
+			},
+		)
+		assert.NoError(err, "mode %s", mode)
+		assert.True(called, "mode %s", mode)
+		assert.Equal(expect, gtidSet, "mode %s", mode)
+	}
+
+	// Not a replica.
+	called := false
+	_, err := fulldump.FullDumpOpts(
+		context.Background(),
+		cfg,
+		&fulldump.Options{Lock: fulldump.LockReplica},
+		func(ctx context.Context, q sqlh.Queryer) error {
+			called = true
+			return nil
+		},
+	)
+	assert.Error(err)
+	assert.Contains(err.Error(), "not a replica")
+	assert.False(called)
+
+}
+
+func TestLockReplica(t *testing.T) {
+
+	assert := assert.New(t)
+
+	_, srcDB, cfg, db, cleanup := runMySQLReplica()
+	defer cleanup()
+
+	exec := func(query string, args ...interface{}) {
+		if _, err := srcDB.Exec(query, args...); err != nil {
+			log.Panic(err)
+		}
+	}
+	count := func(ctx context.Context, q sqlh.Queryer) int {
+		var n int
+		if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM tst.replica").Scan(&n); err != nil {
+			log.Panic(err)
+		}
+		return n
+	}
+
+	exec("CREATE TABLE tst.replica (id int primary key)")
+	exec("INSERT INTO tst.replica VALUES (1)")
+	waitReplica(srcDB, db)
+
+	var expect string
+	assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&expect))
+
+	gtidSet, err := fulldump.FullDumpOpts(
+		context.Background(),
+		cfg,
+		&fulldump.Options{Lock: fulldump.LockReplica},
+		func(ctx context.Context, q sqlh.Queryer) error {
+			assert.Equal(1, count(ctx, q))
+
+			// The SQL thread has been restarted, changes are applied to the replica
+			// but not seen by the snapshot.
+			exec("INSERT INTO tst.replica VALUES (2)")
+			waitReplica(srcDB, db)
+			assert.Equal(2, count(ctx, db))
+			assert.Equal(1, count(ctx, q))
+			return nil
+		},
+	)
+	assert.NoError(err)
+	assert.Equal(expect, gtidSet)
+
+	var running string
+	assert.NoError(db.QueryRow("SELECT SERVICE_STATE FROM performance_schema.replication_applier_status").Scan(&running))
+	assert.Equal("ON", running)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+func TestValueMapper(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec(`CREATE TABLE tst.mapped (
+		id int primary key,
+		d decimal(10, 3),
+		raw_d decimal(10, 3),
+		j json,
+		dt datetime(6),
+		ts timestamp(6),
+		b bit(12)
+	)`)
+	if err != nil {
+		log.Panic(err)
+	}
+	_, err = db.Exec(`INSERT INTO tst.mapped VALUES (
+		1, 1.5, 1.5, '{"b": 1, "a": [1, 2]}', '2020-02-20 20:20:20.123456', '2020-02-20 20:20:20.123456', b'101010101010'
+	)`)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	shanghai := time.FixedZone("Asia/Shanghai", 8*3600)
+	mapper := &ValueMapper{
+		DecimalAsDecimal:  true,
+		JSONAsRawMessage:  true,
+		DatetimeLocation:  shanghai,
+		TimestampLocation: shanghai,
+		BitAsUint64:       true,
+		Columns: map[string]*ValueMapper{
+			"tst.mapped.raw_d": {},
+		},
+	}
+
+	var fullDumpVals map[string]interface{}
+	gset, err := fulldump.FullDump(context.Background(), cfg, func(ctx context.Context, q sqlh.Queryer) error {
+		iter, err := (&fulldump.TableQuery{
+			Table:       fulldump.TableRef{Schema: "tst", Table: "mapped"},
+			ValueMapper: mapper,
+		}).Query(ctx, q)
+		if err != nil {
+			return err
+		}
+		defer iter(false)
+		fullDumpVals, err = iter(true)
+		return err
+	})
+	assert.NoError(err)
+
+	assert.Equal(decimal.RequireFromString("1.500"), fullDumpVals["d"])
+	assert.Equal("1.500", fullDumpVals["raw_d"])
+	assert.Equal(json.RawMessage(`{"a": [1, 2], "b": 1}`), fullDumpVals["j"])
+	assert.Equal(time.Date(2020, 2, 20, 20, 20, 20, 123456000, shanghai), fullDumpVals["dt"])
+	assert.Equal(
+		time.Date(2020, 2, 20, 20, 20, 20, 123456000, time.UTC).In(shanghai),
+		fullDumpVals["ts"],
+	)
+	assert.Equal(uint64(0xaaa), fullDumpVals["b"])
+
+	_, err = db.Exec("DELETE FROM tst.mapped")
+	assert.NoError(err)
+
+	var incrDumpVals map[string]interface{}
+	ctx, cancel := context.WithCancel(context.Background())
+	err = incrdump.IncrDumpOpts(
+		ctx,
+		cfg,
+		gset,
+		&incrdump.Options{
+			Canonical:   true,
+			ValueMapper: mapper,
+		},
+		func(ctx context.Context, e interface{}) error {
+			if ev, ok := e.(*incrdump.RowDeletion); ok {
+				incrDumpVals = ev.BeforeDataMap()
+				cancel()
+			}
+			return nil
+		},
+	)
+	assert.NoError(err)
+
+	for name, v := range fullDumpVals {
+		switch val := v.(type) {
+		case time.Time:
+			assert.True(val.Equal(incrDumpVals[name].(time.Time)), "column %s", name)
+			assert.Equal(val.Location(), incrDumpVals[name].(time.Time).Location(), "column %s", name)
+		default:
+			assert.Equal(v, incrDumpVals[name], "column %s", name)
+		}
+	}
+
+}
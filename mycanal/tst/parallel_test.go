@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+func TestParallelDump(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec("CREATE TABLE tst.`order` (a int, b varchar(16), v int, primary key (a, b))")
+	if err != nil {
+		log.Panic(err)
+	}
+	_, err = db.Exec("CREATE TABLE tst.nopk (v int)")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		_, err = db.Exec("INSERT INTO tst.`order` (a, b, v) VALUES (?, ?, ?), (?, ?, ?)", i/2, fmt.Sprintf("x%d", i), i, i/2, fmt.Sprintf("Y%d", i), i+n)
+		if err != nil {
+			log.Panic(err)
+		}
+		_, err = db.Exec("INSERT INTO tst.nopk (v) VALUES (?)", i)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	for _, parallelism := range []int{1, 3} {
+		var mu sync.Mutex
+		seen := map[string]map[int32]int{}
+		var final *fulldump.Progress
+		tracker := fulldump.NewProgressTracker()
+
+		gtidSet, err := fulldump.ParallelDump(
+			context.Background(),
+			cfg,
+			&fulldump.Options{
+				Parallelism: parallelism,
+				ChunkSize:   77,
+				Progress:    tracker,
+				OnProgress: func(p *fulldump.Progress) {
+					final = p
+				},
+			},
+			[]fulldump.TableRef{
+				{Schema: "tst", Table: "order"},
+				{Schema: "tst", Table: "nopk"},
+			},
+			func(ctx context.Context, chunk *fulldump.Chunk, iter fulldump.RowIter) error {
+				for {
+					row, err := iter(true)
+					if err != nil {
+						return err
+					}
+					if row == nil {
+						return nil
+					}
+					mu.Lock()
+					if seen[chunk.Table] == nil {
+						seen[chunk.Table] = map[int32]int{}
+					}
+					seen[chunk.Table][row["v"].(int32)]++
+					mu.Unlock()
+				}
+			},
+		)
+		assert.NoError(err)
+		assert.NotEmpty(gtidSet)
+
+		// Every row is dumped exactly once.
+		assert.Len(seen["order"], 2*n)
+		assert.Len(seen["nopk"], n)
+		for _, m := range seen {
+			for v, cnt := range m {
+				assert.Equal(1, cnt, "v=%d", v)
+			}
+		}
+
+		// Final progress is reported.
+		assert.Equal(tracker.Progress().Rows, final.Rows)
+		assert.Equal(int64(3*n), final.Rows)
+		assert.Equal(1.0, final.Fraction)
+		assert.Len(final.Tables, 2)
+		for _, table := range final.Tables {
+			assert.True(table.Done, table.Table)
+			assert.True(table.Bytes > 0, table.Table)
+		}
+	}
+
+	// FullDumpOpts with progress tracked by handler.
+	var final *fulldump.Progress
+	tracker := fulldump.NewProgressTracker()
+	table := fulldump.TableRef{Schema: "tst", Table: "nopk"}
+	_, err = fulldump.FullDumpOpts(
+		context.Background(),
+		cfg,
+		&fulldump.Options{
+			Progress: tracker,
+			OnProgress: func(p *fulldump.Progress) {
+				final = p
+			},
+		},
+		func(ctx context.Context, q sqlh.Queryer) error {
+			if err := tracker.AddTables(ctx, q, []fulldump.TableRef{table}); err != nil {
+				return err
+			}
+			iter, err := fulldump.FullTableQuery(ctx, q, table.Schema, table.Table)
+			if err != nil {
+				return err
+			}
+			iter = tracker.Track(table, iter)
+			defer iter(false)
+			for {
+				row, err := iter(true)
+				if row == nil || err != nil {
+					return err
+				}
+			}
+		},
+	)
+	assert.NoError(err)
+	assert.Equal(int64(n), final.Rows)
+	assert.Equal(1.0, final.Fraction)
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+)
+
+func TestPasswordRotation(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	for _, stmt := range []string{
+		"CREATE TABLE tst.rotation (id int primary key)",
+		"CREATE USER 'rot'@'%' IDENTIFIED BY 'p1'",
+		"GRANT SELECT, REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO 'rot'@'%'",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "mycanal")
+	if err != nil {
+		log.Panic(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "password")
+	assert.NoError(ioutil.WriteFile(path, []byte("p1\n"), 0600))
+
+	rotCfg := *cfg
+	rotCfg.User = "rot"
+	rotCfg.Password = ""
+	rotCfg.PasswordProvider = &FilePasswordProvider{Path: path}
+
+	// Client.
+	client, err := rotCfg.Client()
+	assert.NoError(err)
+	assert.NoError(client.Ping())
+	client.Close()
+
+	// Incrdump.
+	var gtidSet string
+	assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	inserted := int64(0)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := incrdump.IncrDump(ctx, &rotCfg, gtidSet, func(ctx context.Context, e interface{}) error {
+			if _, ok := e.(*incrdump.RowInsertion); ok {
+				atomic.AddInt64(&inserted, 1)
+			}
+			return nil
+		})
+		assert.NoError(err)
+	}()
+
+	waitInserted := func(n int64) {
+		for i := 0; i < 100 && atomic.LoadInt64(&inserted) < n; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.Equal(n, atomic.LoadInt64(&inserted))
+	}
+
+	_, err = db.Exec("INSERT INTO tst.rotation VALUES (1)")
+	assert.NoError(err)
+	waitInserted(1)
+
+	// Rotate password and kill the binlog connection.
+	_, err = db.Exec("ALTER USER 'rot'@'%' IDENTIFIED BY 'p2'")
+	assert.NoError(err)
+	assert.NoError(ioutil.WriteFile(path, []byte("p2\n"), 0600))
+
+	var id int64
+	assert.NoError(db.QueryRow("SELECT ID FROM information_schema.PROCESSLIST WHERE USER='rot' AND COMMAND LIKE 'Binlog Dump%'").Scan(&id))
+	_, err = db.Exec("KILL ?", id)
+	assert.NoError(err)
+
+	_, err = db.Exec("INSERT INTO tst.rotation VALUES (2), (3)")
+	assert.NoError(err)
+	waitInserted(3)
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+func TestPreflight(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	report, err := Preflight(context.Background(), cfg)
+	assert.NoError(err)
+	assert.True(report.OK(), "%+v", report.Err())
+
+	// A user without privileges.
+	for _, stmt := range []string{
+		"CREATE USER 'limited'@'%' IDENTIFIED BY 'limited'",
+		"GRANT SELECT, REPLICATION CLIENT ON *.* TO 'limited'@'%'",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	limitedCfg := *cfg
+	limitedCfg.User = "limited"
+	limitedCfg.Password = "limited"
+	report, err = Preflight(context.Background(), &limitedCfg)
+	assert.NoError(err)
+	assert.False(report.OK())
+
+	failures := []string{}
+	for _, check := range report.Failures() {
+		failures = append(failures, check.Name)
+	}
+	assert.Equal([]string{"grant REPLICATION SLAVE", "grant RELOAD or BACKUP_ADMIN"}, failures)
+
+	// LockReplica needs REPLICATION_SLAVE_ADMIN or SUPER instead.
+	report, err = PreflightOpts(context.Background(), &limitedCfg, &PreflightOptions{LockReplica: true})
+	assert.NoError(err)
+	failures = []string{}
+	for _, check := range report.Failures() {
+		failures = append(failures, check.Name)
+	}
+	assert.Equal([]string{"grant REPLICATION SLAVE", "grant REPLICATION_SLAVE_ADMIN or SUPER"}, failures)
+
+	if _, err := db.Exec("GRANT REPLICATION SLAVE, REPLICATION_SLAVE_ADMIN ON *.* TO 'limited'@'%'"); err != nil {
+		log.Panic(err)
+	}
+	report, err = PreflightOpts(context.Background(), &limitedCfg, &PreflightOptions{LockReplica: true})
+	assert.NoError(err)
+	assert.True(report.OK(), "%+v", report.Err())
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+// TestQueryUnsigned checks output types of unsigned columns with the driver in go.mod,
+// with and without information_schema (QueryOptions.Table).
+func TestQueryUnsigned(t *testing.T) {
+
+	assert := assert.New(t)
+
+	_, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err := db.Exec(`CREATE TABLE tst.unsigned (
+		id int primary key,
+		n_tinyint tinyint unsigned default null,
+		n_int int unsigned default null,
+		n_bigint bigint unsigned default null,
+		nn_bigint bigint unsigned not null,
+		n_signed int default null
+	)`)
+	if err != nil {
+		log.Panic(err)
+	}
+	_, err = db.Exec("INSERT INTO tst.unsigned VALUES (1, 255, 4294967295, 18446744073709551615, 18446744073709551615, -1), (2, NULL, NULL, NULL, 0, NULL)")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	expects := []map[string]interface{}{
+		{
+			"id":        int32(1),
+			"n_tinyint": uint8(255),
+			"n_int":     uint32(4294967295),
+			"n_bigint":  uint64(18446744073709551615),
+			"nn_bigint": uint64(18446744073709551615),
+			"n_signed":  int32(-1),
+		},
+		{
+			"id":        int32(2),
+			"n_tinyint": nil,
+			"n_int":     nil,
+			"n_bigint":  nil,
+			"nn_bigint": uint64(0),
+			"n_signed":  nil,
+		},
+	}
+
+	for _, opts := range []*fulldump.QueryOptions{
+		nil,
+		{Table: fulldump.TableRef{Schema: "tst", Table: "unsigned"}},
+	} {
+		iter, err := fulldump.QueryOpts(context.Background(), db, opts, "SELECT * FROM tst.unsigned ORDER BY id")
+		assert.NoError(err)
+
+		rows := []map[string]interface{}{}
+		for {
+			row, err := iter(true)
+			assert.NoError(err)
+			if row == nil {
+				break
+			}
+			rows = append(rows, row)
+		}
+		iter(false)
+		assert.Equal(expects, rows)
+	}
+}
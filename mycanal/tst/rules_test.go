@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+)
+
+func TestColumnRules(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err := db.Exec("CREATE TABLE tst.person (id int primary key, ssn varchar(16), email varchar(64), bio text, note varchar(64))")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var gtidSet string
+	assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet))
+
+	_, err = db.Exec("INSERT INTO tst.person VALUES (1, '123', 'a@b.c', 'hello world', 'secret'), (2, NULL, NULL, NULL, NULL)")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	rules := &ColumnRules{
+		Columns: map[string]*ColumnRule{
+			"tst.person.ssn":   {Action: DropColumn},
+			"tst.person.email": {Action: HashColumn},
+			"tst.person.bio":   {Action: TruncateColumn, Length: 5},
+			"tst.person.note":  {Action: ReplaceColumn, Value: "***"},
+		},
+		HashKey: []byte("key"),
+	}
+
+	// Fulldump.
+	fullRows := []map[string]interface{}{}
+	_, err = fulldump.ParallelDump(
+		context.Background(),
+		cfg,
+		&fulldump.Options{ColumnRules: rules},
+		[]fulldump.TableRef{{Schema: "tst", Table: "person"}},
+		func(ctx context.Context, chunk *fulldump.Chunk, iter fulldump.RowIter) error {
+			for {
+				row, err := iter(true)
+				if err != nil || row == nil {
+					return err
+				}
+				fullRows = append(fullRows, row)
+			}
+		},
+	)
+	assert.NoError(err)
+	assert.Len(fullRows, 2)
+	assert.NotContains(fullRows[0], "ssn")
+	assert.Len(fullRows[0]["email"], 64)
+	assert.Equal("hello", fullRows[0]["bio"])
+	assert.Equal("***", fullRows[0]["note"])
+	assert.Nil(fullRows[1]["email"])
+	assert.Nil(fullRows[1]["note"])
+
+	// Incrdump.
+	incrRows := []map[string]interface{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		incrdump.IncrDumpOpts(ctx, cfg, gtidSet, &incrdump.Options{ColumnRules: rules}, func(ctx context.Context, e interface{}) error {
+			ev, ok := e.(*incrdump.RowInsertion)
+			if !ok || ev.TableName() != "person" {
+				return nil
+			}
+			assert.Equal([]string{"id", "email", "bio", "note"}, ev.ColumnNames())
+			assert.Len(ev.AfterData(), 4)
+			incrRows = append(incrRows, ev.AfterDataMap())
+			if len(incrRows) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	wg.Wait()
+
+	assert.Equal(fullRows, incrRows)
+}
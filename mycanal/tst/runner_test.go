@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+	"github.com/huangjunwen/golibs/mycanal/runner"
+)
+
+func TestRunner(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec("CREATE TABLE tst.run (id int primary key)")
+	if err != nil {
+		log.Panic(err)
+	}
+	_, err = db.Exec("INSERT INTO tst.run VALUES (1), (2), (3)")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	run := func(checkpoint string, insertId int) (events []string, lastGtidSet string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err := runner.Run(ctx, cfg, &runner.Options{
+			Tables:     &fulldump.TableFilter{Include: []string{"tst.run"}},
+			Checkpoint: checkpoint,
+		}, func(ctx context.Context, e interface{}) error {
+			switch ev := e.(type) {
+			case *runner.RowSnapshot:
+				events = append(events, "snapshot")
+
+			case *runner.SnapshotEnding:
+				events = append(events, "snapshot end")
+				lastGtidSet = ev.GTIDSet
+				go db.Exec("INSERT INTO tst.run VALUES (?)", insertId)
+
+			case *incrdump.RowInsertion:
+				events = append(events, "insert")
+
+			case *incrdump.TrxEnding:
+				lastGtidSet = ev.TrxContext().AfterGTIDSet().String()
+				cancel()
+			}
+			return nil
+		})
+		assert.NoError(err)
+		return
+	}
+
+	// Snapshot then stream.
+	events, checkpoint := run("", 4)
+	assert.Equal([]string{"snapshot", "snapshot", "snapshot", "snapshot end", "insert"}, events)
+
+	// Resume from checkpoint: no snapshot.
+	go func() {
+		time.Sleep(time.Second)
+		db.Exec("INSERT INTO tst.run VALUES (5)")
+	}()
+	events, _ = run(checkpoint, 0)
+	assert.Equal([]string{"insert"}, events)
+
+}
+
+// TestRunnerValueOptions checks that snapshot rows and binlog row changes have the same
+// representation and that snapshot progress is tracked.
+func TestRunnerValueOptions(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec("CREATE TABLE tst.run_values (id int primary key, j json, t time(3), dt datetime(3), s varchar(16))")
+	if err != nil {
+		log.Panic(err)
+	}
+	const values = `'{"b": [1, "x"], "a": null}', '-01:02:03.456', '2021-01-02 03:04:05.678', 'secret'`
+	_, err = db.Exec("INSERT INTO tst.run_values VALUES (1, " + values + ")")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var (
+		snapshotRow map[string]interface{}
+		binlogRow   map[string]interface{}
+		progress    *fulldump.Progress
+	)
+	err = runner.Run(ctx, cfg, &runner.Options{
+		Tables: &fulldump.TableFilter{Include: []string{"tst.run_values"}},
+		Dump: &fulldump.Options{
+			OnProgress: func(p *fulldump.Progress) { progress = p },
+		},
+		Canonical:    true,
+		TimeAsString: true,
+		ValueMapper:  &ValueMapper{DatetimeLocation: time.FixedZone("UTC+8", 8*3600)},
+		ColumnRules: &ColumnRules{
+			Columns: map[string]*ColumnRule{"tst.run_values.s": {Action: HashColumn}},
+			HashKey: []byte("key"),
+		},
+	}, func(ctx context.Context, e interface{}) error {
+		switch ev := e.(type) {
+		case *runner.RowSnapshot:
+			snapshotRow = ev.DataMap
+
+		case *runner.SnapshotEnding:
+			go db.Exec("INSERT INTO tst.run_values VALUES (2, " + values + ")")
+
+		case *incrdump.RowInsertion:
+			binlogRow = ev.AfterDataMap()
+			cancel()
+		}
+		return nil
+	})
+	assert.NoError(err)
+
+	assert.NotNil(snapshotRow)
+	assert.NotNil(binlogRow)
+	delete(snapshotRow, "id")
+	delete(binlogRow, "id")
+	assert.Equal(snapshotRow, binlogRow)
+	assert.Equal(`{"a": null, "b": [1, "x"]}`, snapshotRow["j"])
+	assert.Equal("-01:02:03.456", snapshotRow["t"])
+	assert.NotEqual("secret", snapshotRow["s"])
+
+	if assert.NotNil(progress) {
+		assert.Equal(int64(1), progress.Rows)
+	}
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+	"github.com/huangjunwen/golibs/sqlh"
+)
+
+func TestDumpSchemas(t *testing.T) {
+
+	assert := assert.New(t)
+
+	_, db, cleanup := runMySQL()
+	defer cleanup()
+
+	for _, stmt := range []string{
+		"CREATE DATABASE db1",
+		"CREATE DATABASE db2",
+		"CREATE TABLE db1.b (id int primary key)",
+		"CREATE TABLE db1.a (id int primary key)",
+		"CREATE TABLE db2.log_1 (id int primary key)",
+		"CREATE TABLE db2.c (id int primary key)",
+		"CREATE VIEW db1.v AS SELECT * FROM db1.a",
+		"INSERT INTO db1.a VALUES (1), (2), (3)",
+		"INSERT INTO db2.c VALUES (1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	events := []string{}
+	err := fulldump.DumpSchemas(
+		context.Background(),
+		(sqlh.Queryer)(db),
+		&fulldump.TableFilter{
+			Include: []string{"db1.*", "db2.*"},
+			Exclude: []string{"*.log_*"},
+		},
+		nil,
+		func(ctx context.Context, e interface{}) error {
+			switch ev := e.(type) {
+			case *fulldump.TableBeginning:
+				events = append(events, "begin "+ev.Schema+"."+ev.Table)
+
+			case *fulldump.TableRows:
+				for {
+					row, err := ev.Iter(true)
+					if err != nil {
+						return err
+					}
+					if row == nil {
+						return nil
+					}
+				}
+
+			case *fulldump.TableEnding:
+				assert.True(ev.EstimatedRows >= 0)
+				events = append(events, "end "+ev.Schema+"."+ev.Table)
+				switch ev.Table {
+				case "a":
+					assert.Equal(int64(3), ev.Rows)
+				case "b":
+					assert.Equal(int64(0), ev.Rows)
+				case "c":
+					assert.Equal(int64(1), ev.Rows)
+				}
+			}
+			return nil
+		},
+	)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"begin db1.a",
+		"end db1.a",
+		"begin db1.b",
+		"end db1.b",
+		"begin db2.c",
+		"end db2.c",
+	}, events)
+
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+)
+
+func TestAllocServerId(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// The server's own id.
+	_, err := AllocServerId(ctx, cfg, &ServerIdOptions{Min: 1, Max: 1, Retries: 2})
+	assert.Error(err)
+
+	id, err := AllocServerId(ctx, cfg, &ServerIdOptions{Min: 100, Max: 200})
+	assert.NoError(err)
+	assert.True(id >= 100 && id <= 200)
+
+	// Start incrdump with an allocated id.
+	var gtidSet string
+	assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet))
+
+	incrCfg := *cfg
+	incrCfg.ServerId = 0
+
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		wg        sync.WaitGroup
+		allocated uint32
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := incrdump.IncrDumpOpts(ctx, &incrCfg, gtidSet, &incrdump.Options{
+			ServerId:   &ServerIdOptions{Min: 300, Max: 300},
+			OnServerId: func(serverId uint32) { allocated = serverId },
+		}, func(ctx context.Context, e interface{}) error {
+			return nil
+		})
+		assert.NoError(err)
+	}()
+
+	// Wait for the replica to register.
+	var used bool
+	for i := 0; i < 50 && !used; i++ {
+		time.Sleep(100 * time.Millisecond)
+		var n int
+		assert.NoError(db.QueryRow("SELECT COUNT(*) FROM performance_schema.threads WHERE PROCESSLIST_COMMAND LIKE 'Binlog Dump%'").Scan(&n))
+		used = n > 0
+	}
+	assert.True(used)
+
+	_, err = AllocServerId(context.Background(), cfg, &ServerIdOptions{Min: 300, Max: 300, Retries: 2})
+	assert.Error(err)
+
+	cancel()
+	wg.Wait()
+	assert.Equal(uint32(300), allocated)
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/incrdump"
+)
+
+func TestIncrDumpStop(t *testing.T) {
+
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err := db.Exec("CREATE TABLE tst.stop (id int primary key)")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var start string
+	assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&start))
+
+	gtidSets := []string{}
+	var cutoff time.Time
+	for i := 0; i < 5; i++ {
+		_, err = db.Exec("INSERT INTO tst.stop VALUES (?)", i)
+		if err != nil {
+			log.Panic(err)
+		}
+		var gtidSet string
+		assert.NoError(db.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet))
+		gtidSets = append(gtidSets, gtidSet)
+
+		// A cutoff time between the 3rd and the 4th trxs.
+		if i == 2 {
+			time.Sleep(100 * time.Millisecond)
+			var us int64
+			assert.NoError(db.QueryRow("SELECT FLOOR(UNIX_TIMESTAMP(NOW(6)) * 1000000)").Scan(&us))
+			cutoff = time.Unix(us/1e6, us%1e6*1e3)
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	run := func(opts *incrdump.Options) ([]int32, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ids := []int32{}
+		err := incrdump.IncrDumpOpts(ctx, cfg, start, opts, func(ctx context.Context, e interface{}) error {
+			if ev, ok := e.(*incrdump.RowInsertion); ok {
+				ids = append(ids, ev.AfterDataMap()["id"].(int32))
+			}
+			return nil
+		})
+		return ids, err
+	}
+
+	// Stop at gtid set.
+	ids, err := run(&incrdump.Options{StopAtGTIDSet: gtidSets[2]})
+	assert.Equal(incrdump.ErrStopped, err)
+	assert.Equal([]int32{0, 1, 2}, ids)
+
+	// Already reached.
+	ids, err = run(&incrdump.Options{StopAtGTIDSet: start})
+	assert.Equal(incrdump.ErrStopped, err)
+	assert.Empty(ids)
+
+	// Stop after trxs.
+	ids, err = run(&incrdump.Options{StopAfterTrxs: 2})
+	assert.Equal(incrdump.ErrStopped, err)
+	assert.Equal([]int32{0, 1}, ids)
+
+	// Stop at time between trxs.
+	ids, err = run(&incrdump.Options{StopAtTime: cutoff})
+	assert.Equal(incrdump.ErrStopped, err)
+	assert.Equal([]int32{0, 1, 2}, ids)
+
+	// Stop at time: all trxs are committed after it.
+	ids, err = run(&incrdump.Options{StopAtTime: time.Now().Add(-time.Hour)})
+	assert.Equal(incrdump.ErrStopped, err)
+	assert.Empty(ids)
+}
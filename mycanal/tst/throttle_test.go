@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huangjunwen/golibs/mycanal/fulldump"
+)
+
+func TestThrottle(t *testing.T) {
+
+	var err error
+	assert := assert.New(t)
+
+	cfg, db, cleanup := runMySQL()
+	defer cleanup()
+
+	_, err = db.Exec("CREATE TABLE tst.throttle (id int primary key)")
+	if err != nil {
+		log.Panic(err)
+	}
+	for i := 0; i < 50; i++ {
+		_, err = db.Exec("INSERT INTO tst.throttle (id) VALUES (?)", i)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	ctx := context.Background()
+
+	// Probes.
+	running, err := fulldump.ThreadsRunning(db)(ctx)
+	assert.NoError(err)
+	assert.True(running >= 1)
+
+	_, err = fulldump.ReplicaLag(db)(ctx)
+	assert.Error(err)
+
+	// Throttled dump.
+	start := time.Now()
+	n := int64(0)
+	_, err = fulldump.ParallelDump(
+		ctx,
+		cfg,
+		&fulldump.Options{
+			Parallelism: 2,
+			ChunkSize:   10,
+			Throttle: &fulldump.Throttle{
+				RowsPerSecond:  200,
+				Probe:          fulldump.ThreadsRunning(db),
+				ProbeThreshold: 1000,
+			},
+		},
+		[]fulldump.TableRef{{Schema: "tst", Table: "throttle"}},
+		func(ctx context.Context, chunk *fulldump.Chunk, iter fulldump.RowIter) error {
+			for {
+				row, err := iter(true)
+				if err != nil || row == nil {
+					return err
+				}
+				atomic.AddInt64(&n, 1)
+			}
+		},
+	)
+	assert.NoError(err)
+	assert.Equal(int64(50), n)
+	assert.True(time.Since(start) >= 200*time.Millisecond)
+}
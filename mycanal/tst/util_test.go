@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	tstmysql "github.com/huangjunwen/tstsvc/mysql"
+	"github.com/ory/dockertest/v3"
+
+	. "github.com/huangjunwen/golibs/mycanal"
+)
+
+// runMySQL starts a test mysql server satisfying mycanal's prerequisites.
+// Caller should invoke the returned cleanup function at the end.
+func runMySQL() (cfg *Config, db *sql.DB, cleanup func()) {
+	_, cfg, db, cleanup = runMySQLServer(1)
+	return
+}
+
+// runMySQLReplica starts a source and a replica (with log_replica_updates) replicating from it.
+// Caller should invoke the returned cleanup function at the end.
+func runMySQLReplica() (srcCfg *Config, srcDB *sql.DB, cfg *Config, db *sql.DB, cleanup func()) {
+	resSrc, srcCfg, srcDB, srcCleanup := runMySQLServer(1)
+
+	_, cfg, db, replCleanup := runMySQLServer(2)
+	cleanup = func() {
+		replCleanup()
+		srcCleanup()
+	}
+
+	for _, stmt := range []string{
+		fmt.Sprintf(
+			"CHANGE MASTER TO MASTER_HOST='%s', MASTER_PORT=3306, MASTER_USER='root', MASTER_PASSWORD='%s', MASTER_AUTO_POSITION=1, GET_MASTER_PUBLIC_KEY=1",
+			resSrc.Container.NetworkSettings.IPAddress,
+			resSrc.Options.RootPassword,
+		),
+		"START SLAVE",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			cleanup()
+			log.Panic(err)
+		}
+	}
+	log.Printf("MySQL replica started.\n")
+
+	return srcCfg, srcDB, cfg, db, cleanup
+}
+
+// waitReplica waits until the replica has executed all trxs executed on the source.
+func waitReplica(srcDB, db *sql.DB) {
+	var gtidSet string
+	if err := srcDB.QueryRow("SELECT @@GLOBAL.GTID_EXECUTED").Scan(&gtidSet); err != nil {
+		log.Panic(err)
+	}
+	var ret int
+	if err := db.QueryRow("SELECT WAIT_FOR_EXECUTED_GTID_SET(?, 30)", gtidSet).Scan(&ret); err != nil {
+		log.Panic(err)
+	}
+	if ret != 0 {
+		log.Panic("wait replica timeout")
+	}
+}
+
+func runMySQLServer(serverId int) (resMySQL *tstmysql.Resource, cfg *Config, db *sql.DB, cleanup func()) {
+	resMySQL, err := tstmysql.Run(&tstmysql.Options{
+		Tag: "8.0.19",
+		BaseRunOptions: dockertest.RunOptions{
+			Cmd: []string{
+				"--gtid-mode=ON",
+				"--enforce-gtid-consistency=ON",
+				"--log-bin=/var/lib/mysql/binlog",
+				fmt.Sprintf("--server-id=%d", serverId),
+				"--binlog-format=ROW",
+				"--binlog-row-image=full",
+				"--binlog-row-metadata=full",
+			},
+		},
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	log.Printf("MySQL server started.\n")
+
+	db, err = resMySQL.Client()
+	if err != nil {
+		resMySQL.Close()
+		log.Panic(err)
+	}
+	log.Printf("MySQL client created.\n")
+
+	cfg = &Config{
+		Host:     "localhost",
+		Port:     resMySQL.Options.HostPort,
+		User:     "root",
+		Password: resMySQL.Options.RootPassword,
+		ServerId: 1001,
+	}
+
+	return resMySQL, cfg, db, func() {
+		db.Close()
+		resMySQL.Close()
+	}
+}